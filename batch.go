@@ -0,0 +1,289 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// batchJob is the conversion of a present document of a batch.
+type batchJob struct {
+	input   string // name of the input document
+	output  string // name of the output file
+	skipped bool   // whether the output file was up to date
+	warns   int    // number of warnings of the conversion
+	err     error  // error of the conversion, if any
+}
+
+// runBatch converts (or builds) the present documents found under the
+// provided paths into the outdir directory, mirroring their paths,
+// running up to njobs conversions in parallel.
+//
+// Paths are either present documents, directories or "dir/..." patterns,
+// whose .slide files are searched recursively.
+// Documents whose output file is newer than all of their input files,
+// and was converted with the same settings, are not converted again.
+func runBatch(cnv beamer.Converter, build bool, outdir string, paths []string, njobs int, diags *diagnostics) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("batch mode needs input files or directories")
+	}
+	ext := ".tex"
+	if build {
+		ext = ".pdf"
+	}
+
+	jobs, err := batchJobs(paths, outdir, ext)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no present document found in %q", paths)
+	}
+
+	if njobs < 1 {
+		njobs = 1
+	}
+	var (
+		ctx   = context.Background()
+		queue = make(chan *batchJob)
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < njobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.run(ctx, cnv, build, diags)
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- &jobs[i]
+	}
+	close(queue)
+	wg.Wait()
+
+	var ok, skipped, failed int
+	for _, job := range jobs {
+		switch {
+		case job.err != nil:
+			failed++
+			log.Printf("FAIL  %s", job.input)
+		case job.skipped:
+			skipped++
+			log.Printf("skip  %s (up to date)", job.input)
+		case job.warns > 0:
+			ok++
+			log.Printf("ok    %s -> %s (%d warning(s))", job.input, job.output, job.warns)
+		default:
+			ok++
+			log.Printf("ok    %s -> %s", job.input, job.output)
+		}
+	}
+	log.Printf("%d converted, %d up to date, %d failed", ok, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d conversion(s) failed", failed)
+	}
+	return nil
+}
+
+// batchJobs returns the conversions of the present documents found under
+// the provided paths, with their output files under outdir.
+func batchJobs(paths []string, outdir, ext string) ([]batchJob, error) {
+	var (
+		jobs []batchJob
+		seen = make(map[string]bool)
+	)
+	add := func(input, rel string) {
+		if seen[input] {
+			return
+		}
+		seen[input] = true
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + ext
+		jobs = append(jobs, batchJob{
+			input:  input,
+			output: filepath.Join(outdir, rel),
+		})
+	}
+
+	absOut, err := filepath.Abs(outdir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		root := path
+		switch {
+		case path == "...":
+			root = "."
+		case strings.HasSuffix(path, "/..."):
+			root = strings.TrimSuffix(path, "/...")
+		}
+		root = filepath.Clean(root)
+		fi, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			add(root, filepath.Base(root))
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// as for the go tool, directories starting with '_' or
+				// '.' are ignored: they hold the assets of documents.
+				base := d.Name()
+				if name != root && (strings.HasPrefix(base, "_") || strings.HasPrefix(base, ".")) {
+					return filepath.SkipDir
+				}
+				if abs, err := filepath.Abs(name); err == nil && abs == absOut {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(name) != ".slide" {
+				return nil
+			}
+			rel, err := filepath.Rel(root, name)
+			if err != nil {
+				return err
+			}
+			add(name, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not walk %q: %w", root, err)
+		}
+	}
+
+	// documents found under different paths may have the same output file.
+	inputs := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		if prev, dup := inputs[job.output]; dup {
+			return nil, fmt.Errorf("%q and %q would both be converted to %q", prev, job.input, job.output)
+		}
+		inputs[job.output] = job.input
+	}
+	return jobs, nil
+}
+
+// run converts (or builds) the present document of the job, unless its
+// output file is up to date.
+func (job *batchJob) run(ctx context.Context, cnv beamer.Converter, build bool, diags *diagnostics) {
+	cnv, tmpl, names, err := configure(cnv, job.input)
+	if err != nil {
+		job.err = err
+		diags.error("%+v", err)
+		return
+	}
+
+	key := settings(cnv, tmpl, build)
+	if upToDate(job.output, key) {
+		job.skipped = true
+		return
+	}
+
+	files := append([]string{job.input}, names...)
+	cnv.OnFile = func(name string) { files = append(files, name) }
+	cnv.Warn = func(w beamer.Warning) {
+		job.warns++
+		diags.warn(w)
+	}
+	if !build {
+		cnv.OutDir = filepath.Dir(job.output)
+	}
+
+	job.err = os.MkdirAll(filepath.Dir(job.output), 0755)
+	if job.err != nil {
+		diags.error("%+v", job.err)
+		return
+	}
+	job.err = convertFile(ctx, cnv, job.input, job.output, build)
+	if job.err != nil {
+		diags.error("%+v", fmt.Errorf("%s: %w", job.input, job.err))
+		return
+	}
+
+	err = writeDeps(job.output, key, files)
+	if err != nil {
+		log.Printf("could not write dependencies of %s: %+v", job.output, err)
+	}
+}
+
+// settings returns the settings of the conversion with the provided
+// converter and slide templates directory, on a single line.
+// Output files converted with other settings are out of date.
+func settings(cnv beamer.Converter, tmpl string, build bool) string {
+	return fmt.Sprintf(
+		"build=%v templates=%q theme=%q color-theme=%q font-theme=%q aspect-ratio=%q logo=%q preamble=%q "+
+			"dpi=%d lossy-images=%v notes=%q video=%q code-backend=%q section-frames=%v "+
+			"engine=%q build-cmd=%q main-font=%q mono-font=%q cjk-font=%q lang=%q root=%q cache=%q",
+		build, tmpl, cnv.Theme, cnv.ColorTheme, cnv.FontTheme, cnv.AspectRatio, cnv.Logo, cnv.Preamble,
+		cnv.DPI, cnv.LossyImages, cnv.Notes, cnv.Video, cnv.CodeBackend, cnv.SectionFrames,
+		cnv.Engine, cnv.BuildCmd, cnv.MainFont, cnv.MonoFont, cnv.CJKFont, cnv.Lang, cnv.Base, cnv.CacheDir,
+	)
+}
+
+// depsName returns the name of the file listing the settings and the
+// input files of the named output file.
+func depsName(output string) string {
+	return output + ".d"
+}
+
+// depsSettings prefixes the settings line of dependency files.
+const depsSettings = "# settings: "
+
+// writeDeps writes the settings of the conversion of the named output
+// file, then the list of its input files, one per line.
+func writeDeps(output, settings string, files []string) error {
+	var o strings.Builder
+	o.WriteString(depsSettings + settings + "\n")
+	for _, name := range files {
+		o.WriteString(name)
+		o.WriteString("\n")
+	}
+	return os.WriteFile(depsName(output), []byte(o.String()), 0644)
+}
+
+// upToDate returns whether the named output file was converted with the
+// provided settings, and is newer than all of its input files, as
+// listed by writeDeps.
+func upToDate(output, settings string) bool {
+	out, err := os.Stat(output)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(depsName(output))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	if !sc.Scan() || sc.Text() != depsSettings+settings {
+		return false
+	}
+	for sc.Scan() {
+		in, err := os.Stat(sc.Text())
+		if err != nil || !out.ModTime().After(in.ModTime()) {
+			return false
+		}
+	}
+	return sc.Err() == nil
+}
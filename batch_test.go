@@ -0,0 +1,288 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+const batchTalk = `Title
+
+* Slide
+
+Some text.
+`
+
+// mkfiles creates the named files under dir, with the content of a
+// present document.
+func mkfiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatalf("could not create directory: %+v", err)
+		}
+		err = os.WriteFile(fname, []byte(batchTalk), 0644)
+		if err != nil {
+			t.Fatalf("could not write file: %+v", err)
+		}
+	}
+}
+
+// rel returns the slash-separated name of the named file, relative to dir.
+func rel(t *testing.T, dir, name string) string {
+	t.Helper()
+	r, err := filepath.Rel(dir, name)
+	if err != nil {
+		t.Fatalf("could not make %q relative to %q: %+v", name, dir, err)
+	}
+	return filepath.ToSlash(r)
+}
+
+func TestBatchJobs(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir,
+		"talks/a.slide",
+		"talks/2021/b.slide",
+		"talks/2021/notes.txt",
+		"talks/_drafts/c.slide",
+		"talks/.git/d.slide",
+		"talks/out/e.slide",
+		"other/f.slide",
+		"g.slide",
+	)
+
+	for _, tc := range []struct {
+		name  string
+		paths []string
+		want  [][2]string // input and output files
+	}{
+		{
+			name:  "dir",
+			paths: []string{"talks"},
+			want: [][2]string{
+				{"talks/2021/b.slide", "talks/out/2021/b.tex"},
+				{"talks/a.slide", "talks/out/a.tex"},
+			},
+		},
+		{
+			name:  "pattern",
+			paths: []string{"talks/..."},
+			want: [][2]string{
+				{"talks/2021/b.slide", "talks/out/2021/b.tex"},
+				{"talks/a.slide", "talks/out/a.tex"},
+			},
+		},
+		{
+			name:  "files",
+			paths: []string{"g.slide", "talks/2021/b.slide", "other"},
+			want: [][2]string{
+				{"g.slide", "talks/out/g.tex"},
+				{"talks/2021/b.slide", "talks/out/b.tex"},
+				{"other/f.slide", "talks/out/f.tex"},
+			},
+		},
+		{
+			name:  "duplicates",
+			paths: []string{"talks/2021", "talks/2021/b.slide"},
+			want: [][2]string{
+				{"talks/2021/b.slide", "talks/out/b.tex"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for _, path := range tc.paths {
+				paths = append(paths, filepath.Join(dir, path))
+			}
+			jobs, err := batchJobs(paths, filepath.Join(dir, "talks/out"), ".tex")
+			if err != nil {
+				t.Fatalf("could not find documents: %+v", err)
+			}
+			var got [][2]string
+			for _, job := range jobs {
+				got = append(got, [2]string{rel(t, dir, job.input), rel(t, dir, job.output)})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid jobs:\ngot= %q\nwant=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBatchJobsCollision(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir, "a/talk.slide", "b/talk.slide")
+
+	_, err := batchJobs([]string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, filepath.Join(dir, "out"), ".tex")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	want := fmt.Sprintf("%q and %q would both be converted to %q",
+		filepath.Join(dir, "a/talk.slide"), filepath.Join(dir, "b/talk.slide"), filepath.Join(dir, "out/talk.tex"),
+	)
+	if got := err.Error(); got != want {
+		t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir, "talks/a.slide", "talks/2021/b.slide", "talks/2021/_figs/c.slide")
+
+	var (
+		cnv   = beamer.Converter{CacheDir: t.TempDir()}
+		diags = &diagnostics{w: io.Discard}
+	)
+	out := filepath.Join(dir, "out")
+	err := runBatch(cnv, false, out, []string{filepath.Join(dir, "talks/...")}, 2, diags)
+	if err != nil {
+		t.Fatalf("could not run batch: %+v", err)
+	}
+
+	var got []string
+	err = filepath.Walk(out, func(name string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			got = append(got, rel(t, dir, name))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("could not walk output directory: %+v", err)
+	}
+	want := []string{"out/2021/b.tex", "out/2021/b.tex.d", "out/a.tex", "out/a.tex.d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid output files:\ngot= %q\nwant=%q", got, want)
+	}
+
+	tex, err := os.ReadFile(filepath.Join(out, "a.tex"))
+	if err != nil {
+		t.Fatalf("could not read output file: %+v", err)
+	}
+	if !strings.Contains(string(tex), `\begin{document}`) {
+		t.Fatalf("invalid output document:\n%s", tex)
+	}
+}
+
+func TestBatchUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir, "talk.slide")
+
+	var (
+		ctx   = context.Background()
+		input = filepath.Join(dir, "talk.slide")
+		cnv   = beamer.Converter{CacheDir: t.TempDir()}
+		diags = &diagnostics{w: io.Discard}
+	)
+	run := func(cnv beamer.Converter) *batchJob {
+		t.Helper()
+		job := &batchJob{input: input, output: filepath.Join(dir, "out", "talk.tex")}
+		job.run(ctx, cnv, false, diags)
+		if job.err != nil {
+			t.Fatalf("could not convert document: %+v", job.err)
+		}
+		return job
+	}
+
+	if job := run(cnv); job.skipped {
+		t.Fatalf("document not converted")
+	}
+	if job := run(cnv); !job.skipped {
+		t.Fatalf("up to date document converted again")
+	}
+
+	theme := cnv
+	theme.Theme = "Madrid"
+	if job := run(theme); job.skipped {
+		t.Fatalf("document not converted again with another theme")
+	}
+	if job := run(theme); !job.skipped {
+		t.Fatalf("up to date document converted again")
+	}
+
+	future := time.Now().Add(time.Hour)
+	err := os.Chtimes(input, future, future)
+	if err != nil {
+		t.Fatalf("could not change modification time: %+v", err)
+	}
+	if job := run(theme); job.skipped {
+		t.Fatalf("modified document not converted again")
+	}
+	past := time.Now().Add(-time.Hour)
+	err = os.Chtimes(input, past, past)
+	if err != nil {
+		t.Fatalf("could not change modification time: %+v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, configName), []byte("engine = \"xelatex\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write configuration file: %+v", err)
+	}
+	if job := run(theme); job.skipped {
+		t.Fatalf("document not converted again with a new configuration file")
+	}
+
+	tmpl, err := os.ReadFile("beamer/templates/beamer.tmpl")
+	if err != nil {
+		t.Fatalf("could not read slide template: %+v", err)
+	}
+	for _, sub := range []string{"a", "b"} {
+		err = os.MkdirAll(filepath.Join(dir, sub), 0755)
+		if err != nil {
+			t.Fatalf("could not create directory: %+v", err)
+		}
+		err = os.WriteFile(filepath.Join(dir, sub, "beamer.tmpl"), tmpl, 0644)
+		if err != nil {
+			t.Fatalf("could not write slide template: %+v", err)
+		}
+	}
+	err = os.WriteFile(filepath.Join(dir, configName), []byte("templates = \"a\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write configuration file: %+v", err)
+	}
+	for _, name := range []string{configName, "a/beamer.tmpl", "b/beamer.tmpl"} {
+		err = os.Chtimes(filepath.Join(dir, name), past, past)
+		if err != nil {
+			t.Fatalf("could not change modification time: %+v", err)
+		}
+	}
+	if job := run(theme); job.skipped {
+		t.Fatalf("document not converted again with other slide templates")
+	}
+	if job := run(theme); !job.skipped {
+		t.Fatalf("up to date document converted again")
+	}
+
+	err = os.WriteFile(filepath.Join(dir, configName), []byte("templates = \"b\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write configuration file: %+v", err)
+	}
+	err = os.Chtimes(filepath.Join(dir, configName), past, past)
+	if err != nil {
+		t.Fatalf("could not change modification time: %+v", err)
+	}
+	if job := run(theme); job.skipped {
+		t.Fatalf("document not converted again with another slide templates directory")
+	}
+
+	err = os.Chtimes(filepath.Join(dir, "b", "beamer.tmpl"), future, future)
+	if err != nil {
+		t.Fatalf("could not change modification time: %+v", err)
+	}
+	if job := run(theme); job.skipped {
+		t.Fatalf("document not converted again with a modified slide template")
+	}
+}
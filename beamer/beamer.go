@@ -0,0 +1,487 @@
+// Copyright 2015 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package beamer converts present documents to LaTeX/Beamer slides.
+package beamer // import "github.com/sbinet/present-tex/beamer"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sbinet/present-tex/latex"
+	"golang.org/x/tools/present"
+)
+
+// Converter converts present documents to LaTeX/Beamer.
+//
+// The zero value is ready to use.
+// A Converter may be used concurrently to run multiple conversions.
+type Converter struct {
+	Theme      string // Beamer theme to use (e.g: Berkeley, Madrid, ...). Default: "default".
+	ColorTheme string // Beamer color theme to use (e.g: beaver, whale, ...). Default: the one of Theme.
+	FontTheme  string // Beamer font theme to use (e.g: serif, structurebold, ...). Default: the one of Theme.
+	DPI        int    // DPI resolution to use for PDF. Default: 72.
+	Templates  fs.FS  // file system holding the beamer.tmpl template. Default: embedded templates.
+	Base       string // directory against which relative paths are resolved. Default: directory of the input document.
+	FS         fs.FS  // file system holding the files referenced by the document (.code, .image, ...). Default: os.DirFS(Base).
+	OutDir     string // directory of the output LaTeX document, against which included files are referenced. Default: current directory.
+	CacheDir   string // directory holding converted images. Default: present-tex directory under the user cache directory.
+
+	// AspectRatio is the aspect ratio of the slides, as a Beamer
+	// aspectratio option (e.g. 169) or in the "16:9" form.
+	// Default: 43.
+	AspectRatio string
+
+	// Logo is the path to an image displayed on each slide, resolved
+	// against Base. Default: none.
+	Logo string
+
+	// Preamble is LaTeX code inserted at the end of the preamble of the
+	// document (e.g. \usepackage commands.)
+	Preamble string
+
+	// LossyImages allows images converted to a format LaTeX can load
+	// (e.g. from GIF, BMP, TIFF or WebP) to use lossy JPEG compression
+	// instead of PNG.
+	LossyImages bool
+
+	Notes string // speaker notes mode: none, only, show or second-screen. Default: none.
+	Video string // video rendering mode: link or movie. Default: link.
+
+	// CodeBackend is the backend used to typeset source code:
+	// minted, listings or native. Default: minted.
+	// The minted backend requires Pygments and 'pdflatex -shell-escape'.
+	CodeBackend string
+
+	// Engine is the LaTeX engine the document is written for:
+	// pdflatex, xelatex or lualatex. Default: pdflatex.
+	// With xelatex and lualatex, Unicode text is emitted as is and
+	// fonts are loaded with fontspec.
+	Engine string
+
+	// BuildCmd is the LaTeX command run by Build: pdflatex, xelatex,
+	// lualatex, latexmk, or the path to a program taking the same
+	// command-line options. Default: Engine.
+	BuildCmd string
+
+	MainFont string // main font, with xelatex or lualatex. Default: Latin Modern.
+	MonoFont string // monospace font, with xelatex or lualatex. Default: Latin Modern Mono.
+	CJKFont  string // CJK font, with xelatex (xeCJK) or lualatex (luatexja). Default: none.
+
+	// Lang is the language of the document, as a babel language name
+	// (e.g. french, ngerman) or an ISO 639-1 code (e.g. fr, de).
+	// It drives hyphenation and the localized date of the title page.
+	// Default: the language set by a "lang:" document tag
+	// (e.g. "Tags: lang:french"), or english.
+	Lang string
+
+	// SectionFrames renders titled sections without content as a
+	// frame holding the centered section title, in addition to the
+	// \section (or \subsection) command.
+	SectionFrames bool
+
+	// Warn, if not nil, is called with warnings about content dropped
+	// or degraded by the conversion, e.g. characters that have no LaTeX
+	// equivalent or raw HTML.
+	Warn func(w Warning)
+
+	// OnFile, if not nil, is called once per conversion with the path,
+	// joined to Base, of each file referenced by the document
+	// (e.g. .code, .image or Markdown images.)
+	OnFile func(path string)
+
+	// state of the current conversion.
+	tmpl          *template.Template // beamer template
+	hasCode       bool               // whether the .slide has a .code or .play directive
+	hasVideo      bool               // whether the .slide has a .video directive
+	hasTable      bool               // whether the .slide has a Markdown table
+	hasStrike     bool               // whether the .slide has Markdown strikethrough text
+	hasTaskList   bool               // whether the .slide has a Markdown task list
+	hasAMSSymb    bool               // whether the .slide has runes transliterated to amssymb commands
+	bundle        bool               // whether asset names are rewritten for a bundle
+	assets        []asset            // files referenced by the document
+	unmappedRunes map[rune]bool      // runes without a LaTeX equivalent
+	files         map[string]bool    // files referenced by the document
+	src           *source            // input document, to locate diagnostics
+	sections      map[string]int     // lines of the sections in the input document
+	frames        []int              // lines of the frames of the LaTeX document in the input document
+	logo          string             // name of the logo image in the LaTeX document
+}
+
+// Convert reads a present document named name from r and writes
+// the corresponding LaTeX/Beamer document to w.
+func (c Converter) Convert(ctx context.Context, w io.Writer, r io.Reader, name string) error {
+	// c is a copy: the conversion state is local to this call.
+	err := c.init()
+	if err != nil {
+		return err
+	}
+	return c.convert(ctx, w, r, name)
+}
+
+func (c *Converter) convert(ctx context.Context, w io.Writer, r io.Reader, name string) error {
+	if c.Base == "" {
+		c.Base = filepath.Dir(name)
+	}
+	if c.FS == nil {
+		c.FS = os.DirFS(c.Base)
+	}
+
+	doc, err := c.parse(r, name)
+	if err != nil {
+		return wrapError(err, "could not parse input document")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = c.setLang(doc)
+	if err != nil {
+		return err
+	}
+
+	flattenSections(doc)
+	c.sections = make(map[string]int, len(doc.Sections))
+	for i, s := range doc.Sections {
+		c.sections[sectionKey(s)] = c.src.section(i)
+	}
+
+	err = c.parseImages(doc)
+	if err != nil {
+		return wrapError(err, "could not parse images")
+	}
+
+	c.parseCode(doc)
+
+	c.parseMedia(doc)
+
+	c.parseHTML(doc)
+
+	err = c.parseColumns(doc)
+	if err != nil {
+		return wrapError(err, "could not parse columns")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = c.render(w, doc)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Converter) init() error {
+	if c.Theme == "" {
+		c.Theme = "default"
+	}
+	if c.DPI <= 0 {
+		c.DPI = 72
+	}
+	if c.Templates == nil {
+		c.Templates = defaultTemplates()
+	}
+	if c.CacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		c.CacheDir = filepath.Join(dir, "present-tex")
+	}
+	if c.Notes == "" {
+		c.Notes = "none"
+	}
+	if _, ok := notesOptions[c.Notes]; !ok {
+		return fmt.Errorf("invalid notes mode %q", c.Notes)
+	}
+	switch c.Video {
+	case "":
+		c.Video = "link"
+	case "link", "movie":
+	default:
+		return fmt.Errorf("invalid video mode %q", c.Video)
+	}
+	switch c.Engine {
+	case "":
+		c.Engine = latex.PDFLaTeX
+	case latex.PDFLaTeX, latex.XeLaTeX, latex.LuaLaTeX:
+	default:
+		return fmt.Errorf("invalid LaTeX engine %q", c.Engine)
+	}
+	switch c.CodeBackend {
+	case "":
+		c.CodeBackend = latex.Minted
+	case latex.Minted, latex.Listings, latex.Native:
+	default:
+		return fmt.Errorf("invalid code backend %q", c.CodeBackend)
+	}
+	if c.AspectRatio != "" {
+		ratio := strings.Replace(c.AspectRatio, ":", "", 1)
+		if !aspectRatios[ratio] {
+			return fmt.Errorf("invalid aspect ratio %q", c.AspectRatio)
+		}
+		c.AspectRatio = ratio
+	}
+	c.tmpl = nil
+	c.hasCode = false
+	c.hasVideo = false
+	c.hasTable = false
+	c.hasStrike = false
+	c.hasTaskList = false
+	c.hasAMSSymb = false
+	c.bundle = false
+	c.assets = nil
+	c.unmappedRunes = nil
+	c.files = nil
+	c.src = nil
+	c.sections = nil
+	c.frames = nil
+	c.logo = ""
+	return nil
+}
+
+// warnf reports a conversion warning, located at the provided line of
+// the input document.
+func (c *Converter) warnf(line int, format string, args ...interface{}) {
+	if c.Warn == nil {
+		return
+	}
+	w := Warning{Msg: fmt.Sprintf(format, args...)}
+	if c.src != nil {
+		w.File = c.src.name
+		w.Line = line
+		w.Slide = c.src.slide(line)
+	}
+	c.Warn(w)
+}
+
+// unmapped reports a rune without a LaTeX equivalent, once per conversion.
+func (c *Converter) unmapped(r rune) {
+	if c.unmappedRunes[r] {
+		return
+	}
+	if c.unmappedRunes == nil {
+		c.unmappedRunes = make(map[rune]bool)
+	}
+	c.unmappedRunes[r] = true
+	line := 0
+	if c.src != nil {
+		line = c.src.contains(string(r))
+	}
+	c.warnf(line, "no LaTeX equivalent for %q (%U) with %s: use the xelatex or lualatex engine", r, r, c.Engine)
+}
+
+// aspectRatios lists the aspect ratios supported by Beamer.
+var aspectRatios = map[string]bool{
+	"1610": true,
+	"169":  true,
+	"149":  true,
+	"141":  true,
+	"54":   true,
+	"43":   true,
+	"32":   true,
+	"235":  true,
+}
+
+// notesOptions maps speaker notes modes to Beamer options.
+var notesOptions = map[string]string{
+	"none":          "",
+	"only":          "show only notes",
+	"show":          "show notes",
+	"second-screen": "show notes on second screen=right",
+}
+
+func (c *Converter) parse(r io.Reader, name string) (*present.Doc, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c.src = newSource(name, raw)
+	c.hasAMSSymb = !latex.Unicode(c.Engine) && latex.UsesAMSSymb(string(raw))
+
+	dir := filepath.Dir(name)
+	ctx := present.Context{
+		ReadFile: func(fname string) ([]byte, error) {
+			// present resolves fname against the directory of the document.
+			fname, err := filepath.Rel(dir, fname)
+			if err != nil {
+				return nil, err
+			}
+			raw, err := c.readFile(fname)
+			if err == nil {
+				c.asset(fname, fname)
+			}
+			return raw, err
+		},
+		Render: c.renderAsLaTeX,
+	}
+
+	doc, err := ctx.Parse(bytes.NewReader(raw), name, 0)
+	if err != nil {
+		return nil, c.src.parseError(err)
+	}
+	return doc, nil
+}
+
+func (c *Converter) render(w io.Writer, doc *present.Doc) error {
+	var err error
+	c.tmpl, err = c.initTemplates()
+	if err != nil {
+		return fmt.Errorf("could not parse templates: %w", err)
+	}
+
+	c.frames = c.frameLines(doc)
+
+	buf := new(bytes.Buffer)
+	err = doc.Render(buf, c.tmpl)
+	if err != nil {
+		return wrapError(err, "could not render document")
+	}
+
+	out := []byte(html.UnescapeString(buf.String()))
+
+	_, err = w.Write(out)
+	if err != nil {
+		return fmt.Errorf("could not fill output: %w", err)
+	}
+
+	return nil
+}
+
+// path resolves name against the base directory of the converter.
+func (c *Converter) path(name string) string {
+	if c.Base == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.Base, name)
+}
+
+// fsName returns the name of the provided file in the file system of the
+// converter, and whether it is a valid name in that file system.
+func (c *Converter) fsName(name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return "", false
+	}
+	name = path.Clean(filepath.ToSlash(name))
+	return name, fs.ValidPath(name)
+}
+
+// readFile reads the named file, relative to the base directory.
+// Files outside of the file system of the converter (absolute paths
+// or paths starting with "..") are read from the local file system.
+func (c *Converter) readFile(name string) ([]byte, error) {
+	c.file(name)
+	if fname, ok := c.fsName(name); ok {
+		return fs.ReadFile(c.FS, fname)
+	}
+	return os.ReadFile(c.path(name))
+}
+
+// file reports the named file, relative to the base directory, as
+// referenced by the document.
+func (c *Converter) file(name string) {
+	if c.OnFile == nil || c.files[name] {
+		return
+	}
+	if c.files == nil {
+		c.files = make(map[string]bool)
+	}
+	c.files[name] = true
+	c.OnFile(c.path(name))
+}
+
+// exists returns whether the named file, relative to the base directory,
+// exists.
+func (c *Converter) exists(name string) bool {
+	var err error
+	if fname, ok := c.fsName(name); ok {
+		_, err = fs.Stat(c.FS, fname)
+	} else {
+		_, err = os.Stat(c.path(name))
+	}
+	return err == nil
+}
+
+// outPath returns the path to the named local file, relative to the
+// output directory.
+func (c *Converter) outPath(name string) string {
+	out, err := filepath.Abs(c.OutDir)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	fname, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	rel, err := filepath.Rel(out, fname)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (c *Converter) initTemplates() (*template.Template, error) {
+	tmpl := template.New("").Funcs(c.funcs()).Delims("<<", ">>")
+	_, err := tmpl.ParseFS(c.Templates, "beamer.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, err
+}
+
+// renderElem implements the elem template function, used to render
+// sub-templates.
+func (c *Converter) renderElem(t *template.Template, e present.Elem) (template.HTML, error) {
+	var data interface{} = e
+	switch e := e.(type) {
+	case present.Section:
+		data = struct {
+			present.Section
+			Template *template.Template
+		}{e, t}
+	case Columns:
+		data = struct {
+			Columns
+			Template *template.Template
+		}{e, t}
+	}
+	out, err := execTemplate(t, e.TemplateName(), data)
+	if err == nil || c.src == nil {
+		return out, err
+	}
+	var perr *Error
+	if errors.As(err, &perr) {
+		return "", perr
+	}
+	if s, ok := e.(present.Section); ok {
+		return "", c.src.errorf(c.sections[sectionKey(s)], "could not render slide: %w", err)
+	}
+	return "", c.src.errorf(c.src.elemLine(e), "could not render %s: %w", e.TemplateName(), err)
+}
+
+// execTemplate is a helper to execute a template and return the output as a
+// template.HTML value.
+func execTemplate(t *template.Template, name string, data interface{}) (template.HTML, error) {
+	b := new(bytes.Buffer)
+	err := t.ExecuteTemplate(b, name, data)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
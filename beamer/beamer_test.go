@@ -0,0 +1,1012 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"image"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testCacheDir is the cache directory used by tests.
+const testCacheDir = "_cache"
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.RemoveAll(testCacheDir)
+	os.Exit(code)
+}
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+		cnv   Converter
+	}{
+		{
+			input: "talk.slide",
+			want:  "talk_golden.tex",
+		},
+		{
+			input: "talk-md.slide",
+			want:  "talk-md_golden.tex",
+		},
+		{
+			input: "notes.slide",
+			want:  "notes_golden.tex",
+		},
+		{
+			input: "notes-md.slide",
+			want:  "notes-md_golden.tex",
+		},
+		{
+			input: "sections.slide",
+			want:  "sections_golden.tex",
+		},
+		{
+			input: "sections.slide",
+			want:  "sections-frames_golden.tex",
+			cnv:   Converter{SectionFrames: true},
+		},
+		{
+			input: "media.slide",
+			want:  "media_golden.tex",
+		},
+		{
+			input: "code.slide",
+			want:  "code_golden.tex",
+		},
+		{
+			input: "tables-md.slide",
+			want:  "tables-md_golden.tex",
+		},
+		{
+			input: "gfm-md.slide",
+			want:  "gfm-md_golden.tex",
+		},
+		{
+			input: "math.slide",
+			want:  "math_golden.tex",
+		},
+		{
+			input: "math-md.slide",
+			want:  "math-md_golden.tex",
+		},
+		{
+			input: "latex.slide",
+			want:  "latex_golden.tex",
+		},
+		{
+			input: "latex-md.slide",
+			want:  "latex-md_golden.tex",
+		},
+		{
+			input: "overlays.slide",
+			want:  "overlays_golden.tex",
+		},
+		{
+			input: "overlays-md.slide",
+			want:  "overlays-md_golden.tex",
+		},
+		{
+			input: "columns.slide",
+			want:  "columns_golden.tex",
+		},
+		{
+			input: "columns-md.slide",
+			want:  "columns-md_golden.tex",
+		},
+		{
+			input: "unicode.slide",
+			want:  "unicode_golden.tex",
+		},
+		{
+			input: "unicode.slide",
+			want:  "unicode-xelatex_golden.tex",
+			cnv: Converter{
+				Engine:   "xelatex",
+				MainFont: "Noto Sans",
+				MonoFont: "Noto Sans Mono",
+				CJKFont:  "Noto Sans CJK SC",
+			},
+		},
+		{
+			input: "unicode-md.slide",
+			want:  "unicode-md-lualatex_golden.tex",
+			cnv:   Converter{Engine: "lualatex"},
+		},
+		{
+			input: "transliterate.slide",
+			want:  "transliterate_golden.tex",
+		},
+		{
+			input: "transliterate-md.slide",
+			want:  "transliterate-md_golden.tex",
+		},
+		{
+			input: "lang.slide",
+			want:  "lang_golden.tex",
+		},
+		{
+			input: "lang.slide",
+			want:  "lang-de_golden.tex",
+			cnv:   Converter{Lang: "de"},
+		},
+		{
+			input: "images-md.slide",
+			want:  "images-md_golden.tex",
+		},
+		{
+			input: "images-md.slide",
+			want:  "images-md-lossy_golden.tex",
+			cnv:   Converter{LossyImages: true},
+		},
+		{
+			input: "code.slide",
+			want:  "code-listings_golden.tex",
+			cnv:   Converter{CodeBackend: "listings"},
+		},
+		{
+			input: "code.slide",
+			want:  "code-native_golden.tex",
+			cnv:   Converter{CodeBackend: "native"},
+		},
+		{
+			input: "code-md.slide",
+			want:  "code-md_golden.tex",
+			cnv:   Converter{CodeBackend: "native"},
+		},
+		{
+			input: "media.slide",
+			want:  "media-movie_golden.tex",
+			cnv:   Converter{Video: "movie"},
+		},
+		{
+			input: "sections.slide",
+			want:  "sections-themes_golden.tex",
+			cnv: Converter{
+				Theme:       "Madrid",
+				ColorTheme:  "beaver",
+				FontTheme:   "serif",
+				AspectRatio: "16:9",
+				Logo:        "_figs/gopher.png",
+				Preamble:    `\usepackage{tikz}`,
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			r, err := os.ReadFile(filepath.Join("testdata", tc.input))
+			if err != nil {
+				t.Fatalf("could not read input file %q: %+v", tc.input, err)
+			}
+
+			want, err := os.ReadFile(filepath.Join("testdata", tc.want))
+			if err != nil {
+				t.Fatalf("could not read golden file %q: %+v", tc.want, err)
+			}
+
+			// relative paths are resolved against the directory of the input document.
+			cnv := tc.cnv
+			cnv.OutDir = "testdata"
+			cnv.CacheDir = testCacheDir
+			w := new(bytes.Buffer)
+			err = cnv.Convert(context.Background(), w, bytes.NewReader(r), filepath.Join("testdata", tc.input))
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+
+			if got := w.Bytes(); !bytes.Equal(got, want) {
+				fname := filepath.Join("testdata", strings.Replace(tc.want, "_golden", "", 1))
+				_ = os.WriteFile(fname, got, 0644)
+				out, _ := exec.Command("diff", "-urN", fname, filepath.Join("testdata", tc.want)).CombinedOutput()
+				t.Fatalf("output documents differ: %q:\n%s", tc.input, out)
+			}
+		})
+	}
+}
+
+func TestConvertConcurrent(t *testing.T) {
+	r, err := os.ReadFile("testdata/talk.slide")
+	if err != nil {
+		t.Fatalf("could not read input file: %+v", err)
+	}
+
+	want, err := os.ReadFile("testdata/talk_golden.tex")
+	if err != nil {
+		t.Fatalf("could not read golden file: %+v", err)
+	}
+
+	var (
+		cnv  = Converter{Base: "testdata", OutDir: "testdata", CacheDir: testCacheDir}
+		wg   sync.WaitGroup
+		n    = 4
+		out  = make([]*bytes.Buffer, n)
+		errs = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out[i] = new(bytes.Buffer)
+			errs[i] = cnv.Convert(context.Background(), out[i], bytes.NewReader(r), "talk.slide")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("conversion %d failed: %+v", i, errs[i])
+		}
+		if !bytes.Equal(out[i].Bytes(), want) {
+			t.Fatalf("conversion %d: output documents differ", i)
+		}
+	}
+}
+
+func TestConvertFS(t *testing.T) {
+	png, err := os.ReadFile("testdata/_figs/gopher.png")
+	if err != nil {
+		t.Fatalf("could not read image file: %+v", err)
+	}
+
+	fsys := fstest.MapFS{
+		"_code/hello.go":   &fstest.MapFile{Data: []byte("package main\n\nfunc main() {}\n")},
+		"_figs/gopher.png": &fstest.MapFile{Data: png},
+	}
+
+	const slide = `Title
+
+Author
+
+* Code
+
+.code _code/hello.go
+
+* Image
+
+.image _figs/gopher.png
+`
+
+	cnv := Converter{FS: fsys, CacheDir: testCacheDir}
+	w := new(bytes.Buffer)
+	err = cnv.Convert(context.Background(), w, strings.NewReader(slide), "talk.slide")
+	if err != nil {
+		t.Fatalf("could not process document: %+v", err)
+	}
+
+	got := w.String()
+	for _, want := range []string{
+		"func main() {}",
+		`\includegraphics[width=3cm,height=4cm]{_figs/gopher.png}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in output", want)
+		}
+	}
+}
+
+func TestUnmapped(t *testing.T) {
+	for _, tc := range []struct {
+		input  string
+		engine string
+		want   []string
+	}{
+		{
+			input: "unicode.slide",
+			want: []string{
+				`unicode.slide:1: no LaTeX equivalent for 'ά' (U+03AC) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode.slide:1: no LaTeX equivalent for '中' (U+4E2D) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode.slide:1: no LaTeX equivalent for '文' (U+6587) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode.slide:8: slide "Text": no LaTeX equivalent for '日' (U+65E5) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode.slide:8: slide "Text": no LaTeX equivalent for '本' (U+672C) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode.slide:8: slide "Text": no LaTeX equivalent for '語' (U+8A9E) with pdflatex: use the xelatex or lualatex engine`,
+			},
+		},
+		{
+			input: "unicode-md.slide",
+			want: []string{
+				`unicode-md.slide:8: slide "Text": no LaTeX equivalent for '日' (U+65E5) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode-md.slide:8: slide "Text": no LaTeX equivalent for '本' (U+672C) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode-md.slide:8: slide "Text": no LaTeX equivalent for '語' (U+8A9E) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode-md.slide:1: no LaTeX equivalent for 'ά' (U+03AC) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode-md.slide:1: no LaTeX equivalent for '中' (U+4E2D) with pdflatex: use the xelatex or lualatex engine`,
+				`unicode-md.slide:1: no LaTeX equivalent for '文' (U+6587) with pdflatex: use the xelatex or lualatex engine`,
+			},
+		},
+		{
+			input:  "unicode.slide",
+			engine: "xelatex",
+		},
+		{
+			input: "transliterate.slide",
+		},
+	} {
+		t.Run(tc.input+":"+tc.engine, func(t *testing.T) {
+			r, err := os.ReadFile(filepath.Join("testdata", tc.input))
+			if err != nil {
+				t.Fatalf("could not read input file: %+v", err)
+			}
+
+			var got []string
+			cnv := Converter{
+				Base:     "testdata",
+				CacheDir: testCacheDir,
+				Engine:   tc.engine,
+				Warn:     func(w Warning) { got = append(got, w.String()) },
+			}
+			err = cnv.Convert(context.Background(), io.Discard, bytes.NewReader(r), tc.input)
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid warnings:\ngot= %q\nwant=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		backend string
+		err     string
+		warn    string
+	}{
+		{
+			name: "image.slide",
+			input: `Title
+
+* First
+
+text
+
+* Second
+
+.image _code/hello.go
+`,
+			err: `image.slide:9: slide "Second": could not parse image "_code/hello.go": `,
+		},
+		{
+			name: "columns.slide",
+			input: `Title
+
+* Columns
+
+.column 0.5
+text
+`,
+			err: `columns.slide:5: slide "Columns": unterminated ".column 0.5" directive`,
+		},
+		{
+			name: "command.slide",
+			input: `Title
+
+* Slide
+
+.unknown foo
+`,
+			err: `command.slide:5: slide "Slide": unknown command ".unknown foo"`,
+		},
+		{
+			name: "markdown-md.slide",
+			input: `# Title
+
+## First
+
+text
+
+## Second
+
+![gopher](_figs/missing.png)
+`,
+			err: `markdown-md.slide:9: slide "Second": could not render Markdown: `,
+		},
+		{
+			name: "code.slide",
+			input: `Title
+
+* Code
+
+.code _tex/tikz.tex
+`,
+			backend: "native",
+			warn:    `code.slide:5: slide "Code": unknown code extension ".tex" for the native code backend`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var warns []string
+			cnv := Converter{
+				Base:        "testdata",
+				CacheDir:    testCacheDir,
+				CodeBackend: tc.backend,
+				Warn:        func(w Warning) { warns = append(warns, w.String()) },
+			}
+			err := cnv.Convert(context.Background(), io.Discard, strings.NewReader(tc.input), tc.name)
+			switch {
+			case err == nil && tc.err != "":
+				t.Fatalf("expected an error")
+			case err != nil && tc.err == "":
+				t.Fatalf("could not process document: %+v", err)
+			case err != nil && !strings.HasPrefix(err.Error(), tc.err):
+				t.Fatalf("invalid error:\ngot= %q\nwant=%q", err, tc.err)
+			}
+			if err != nil {
+				var perr *Error
+				if !errors.As(err, &perr) {
+					t.Fatalf("error is not a *Error: %T", err)
+				}
+			}
+
+			if tc.warn != "" && (len(warns) != 1 || warns[0] != tc.warn) {
+				t.Fatalf("invalid warnings:\ngot= %q\nwant=%q", warns, tc.warn)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		backend string
+		want    []string
+	}{
+		{
+			name: "caption.slide",
+			input: `Title
+
+* Captions
+
+.image _figs/gopher.png
+.caption A gopher
+
+.caption Stray
+`,
+			want: []string{
+				`caption.slide:8: slide "Captions": caption "Stray" not following an image dropped`,
+			},
+		},
+		{
+			name: "html.slide",
+			input: `Title
+
+* HTML
+
+.html _tex/tikz.tex
+`,
+			want: []string{
+				`html.slide:5: slide "HTML": HTML fragment typeset verbatim`,
+			},
+		},
+		{
+			name: "html-md.slide",
+			input: `# Title
+
+## HTML
+
+Some <span>inline</span> HTML.
+
+<div>
+block
+</div>
+`,
+			want: []string{
+				`html-md.slide:5: slide "HTML": inline HTML "<span>" written as is`,
+				`html-md.slide:5: slide "HTML": inline HTML "</span>" written as is`,
+				`html-md.slide:7: slide "HTML": HTML block typeset verbatim`,
+			},
+		},
+		{
+			name: "code-md.slide",
+			input: `# Title
+
+## Code
+
+.code _tex/tikz.tex
+
+` + "```cobol\nDISPLAY 'hello'.\n```" + `
+`,
+			backend: "listings",
+			want: []string{
+				`code-md.slide:7: slide "Code": unknown code language "cobol" for the listings code backend`,
+				`code-md.slide:5: slide "Code": unknown code extension ".tex" for the listings code backend`,
+			},
+		},
+		{
+			name: "video.slide",
+			input: `Title
+
+* Video
+
+.video _media/missing.mp4 video/mp4
+`,
+			want: []string{
+				`video.slide:5: slide "Video": video "_media/missing.mp4" not found`,
+			},
+		},
+		{
+			name: "svg.slide",
+			input: `Title
+
+* SVG
+
+.image _figs/filter.svg
+`,
+			want: []string{
+				`svg.slide:5: slide "SVG": SVG image "_figs/filter.svg" rasterized without its unsupported elements: `,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			cnv := Converter{
+				Base:        "testdata",
+				CacheDir:    t.TempDir(),
+				CodeBackend: tc.backend,
+				Warn:        func(w Warning) { got = append(got, w.String()) },
+			}
+			err := cnv.Convert(context.Background(), io.Discard, strings.NewReader(tc.input), tc.name)
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+
+			ok := len(got) == len(tc.want)
+			for i := 0; ok && i < len(got); i++ {
+				ok = strings.HasPrefix(got[i], tc.want[i])
+			}
+			if !ok {
+				t.Fatalf("invalid warnings:\ngot= %q\nwant=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLang(t *testing.T) {
+	r, err := os.ReadFile("testdata/talk.slide")
+	if err != nil {
+		t.Fatalf("could not read input file: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		lang string
+		want string
+		err  bool
+	}{
+		{lang: "", want: `\usepackage[english]{babel}`},
+		{lang: "FR", want: `\usepackage[french]{babel}`},
+		{lang: "italian", want: `\usepackage[italian]{babel}`},
+		{lang: "french]{x}", err: true},
+	} {
+		t.Run(tc.lang, func(t *testing.T) {
+			cnv := Converter{Base: "testdata", CacheDir: testCacheDir, Lang: tc.lang}
+			w := new(bytes.Buffer)
+			err := cnv.Convert(context.Background(), w, bytes.NewReader(r), "talk.slide")
+			switch {
+			case err != nil && tc.err:
+				return
+			case err != nil:
+				t.Fatalf("could not process document: %+v", err)
+			case tc.err:
+				t.Fatalf("expected an error")
+			}
+
+			if got := w.String(); !strings.Contains(got, tc.want) {
+				t.Fatalf("missing %q in output", tc.want)
+			}
+		})
+	}
+}
+
+func TestAMSSymb(t *testing.T) {
+	const slide = `Title
+
+* Slide
+
+- Done ✓
+`
+	for _, tc := range []struct {
+		engine string
+		want   bool
+	}{
+		{engine: "pdflatex", want: true},
+		{engine: "xelatex", want: false},
+	} {
+		t.Run(tc.engine, func(t *testing.T) {
+			cnv := Converter{CacheDir: testCacheDir, Engine: tc.engine}
+			w := new(bytes.Buffer)
+			err := cnv.Convert(context.Background(), w, strings.NewReader(slide), "talk.slide")
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+
+			got := strings.Contains(w.String(), `\usepackage{amssymb}`)
+			if got != tc.want {
+				t.Fatalf("invalid amssymb package in preamble: got=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoLink(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "url",
+			input: "<https://go.dev/doc>",
+			want:  `\colhref{https://go.dev/doc}{\texttt{https://go.dev/doc}}`,
+		},
+		{
+			name:  "fragment",
+			input: "<https://go.dev/doc#go_mod>",
+			want:  `\colhref{https://go.dev/doc\#go_mod}{\texttt{https://go.dev/doc\#go\_mod}}`,
+		},
+		{
+			name:  "email",
+			input: "<gopher@example.com>",
+			want:  `\colhref{mailto:gopher@example.com}{\texttt{gopher@example.com}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := "# Title\n\n## Links\n\nSee " + tc.input + ".\n"
+			w := new(bytes.Buffer)
+			err := Converter{CacheDir: testCacheDir}.Convert(context.Background(), w, strings.NewReader(src), "link.slide")
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+			if got := w.String(); !strings.Contains(got, "See "+tc.want+".") {
+				t.Fatalf("missing %q in output:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOnFile(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  []string
+	}{
+		{
+			input: "talk.slide",
+			want: []string{
+				"testdata/_code/hello.c",
+				"testdata/_code/hello.go",
+				"testdata/_code/hello.py",
+				"testdata/_figs/gopher.png",
+				"testdata/_figs/gopher.svg",
+			},
+		},
+		{
+			input: "images-md.slide",
+			want: []string{
+				"testdata/_figs/gopher.bmp",
+				"testdata/_figs/gopher.gif",
+				"testdata/_figs/gopher.svg",
+			},
+		},
+		{
+			input: "media.slide",
+			want: []string{
+				"testdata/_figs/gopher.png",
+				"testdata/_media/demo.png",
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			r, err := os.ReadFile(filepath.Join("testdata", tc.input))
+			if err != nil {
+				t.Fatalf("could not read input file: %+v", err)
+			}
+
+			var got []string
+			cnv := Converter{
+				Base:     "testdata",
+				CacheDir: testCacheDir,
+				OnFile:   func(name string) { got = append(got, filepath.ToSlash(name)) },
+			}
+			err = cnv.Convert(context.Background(), io.Discard, bytes.NewReader(r), tc.input)
+			if err != nil {
+				t.Fatalf("could not process document: %+v", err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid referenced files:\ngot= %q\nwant=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSVGResolution(t *testing.T) {
+	raw, err := os.ReadFile("testdata/_figs/gopher.svg")
+	if err != nil {
+		t.Fatalf("could not read SVG image: %+v", err)
+	}
+
+	size := func(dpi int) image.Point {
+		cnv := Converter{DPI: dpi, CacheDir: t.TempDir()}
+		img, err := cnv.images().Image("gopher.svg", raw)
+		if err != nil {
+			t.Fatalf("could not rasterize SVG image: %+v", err)
+		}
+		f, err := os.Open(img.Name)
+		if err != nil {
+			t.Fatalf("could not open rasterized image: %+v", err)
+		}
+		defer f.Close()
+		cfg, _, err := image.DecodeConfig(f)
+		if err != nil {
+			t.Fatalf("could not decode rasterized image: %+v", err)
+		}
+		return image.Pt(cfg.Width, cfg.Height)
+	}
+
+	lo := size(72)
+	hi := size(144)
+	if got, want := hi, lo.Mul(2); got != want {
+		t.Fatalf("invalid rasterized image size: got=%v, want=%v", got, want)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	r, err := os.ReadFile("testdata/notes.slide")
+	if err != nil {
+		t.Fatalf("could not read input file: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		mode string
+		want string
+		err  bool
+	}{
+		{mode: "", want: ""},
+		{mode: "none", want: ""},
+		{mode: "only", want: `\setbeameroption{show only notes}`},
+		{mode: "show", want: `\setbeameroption{show notes}`},
+		{mode: "second-screen", want: `\setbeameroption{show notes on second screen=right}`},
+		{mode: "invalid", err: true},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			cnv := Converter{Base: "testdata", CacheDir: testCacheDir, Notes: tc.mode}
+			w := new(bytes.Buffer)
+			err := cnv.Convert(context.Background(), w, bytes.NewReader(r), "notes.slide")
+			switch {
+			case err != nil && tc.err:
+				return
+			case err != nil:
+				t.Fatalf("could not process document: %+v", err)
+			case tc.err:
+				t.Fatalf("expected an error")
+			}
+
+			got := w.String()
+			switch tc.want {
+			case "":
+				if strings.Contains(got, `\setbeameroption`) {
+					t.Fatalf("unexpected beamer option in output")
+				}
+			default:
+				if !strings.Contains(got, tc.want) {
+					t.Fatalf("missing beamer option %q in output", tc.want)
+				}
+			}
+			if !strings.Contains(got, `\note{`) {
+				t.Fatalf("missing speaker notes in output")
+			}
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	for _, tc := range []struct {
+		input  string
+		format string
+		want   []string
+	}{
+		{
+			input:  "media.slide",
+			format: Zip,
+			want: []string{
+				"Makefile", "_figs/gopher.png", "_media/demo.png",
+				"latexmkrc", "media.tex",
+			},
+		},
+		{
+			input:  "images-md.slide",
+			format: TarGz,
+			want: []string{
+				"Makefile", "_assets/gopher-2a85177c86d8.png", "_assets/gopher-5495fcdd380a-72dpi.png",
+				"_assets/gopher-ee1d179adce6.png", "images-md.tex", "latexmkrc",
+			},
+		},
+		{
+			input:  "code.slide",
+			format: Tar,
+			want: []string{
+				"Makefile", "_code/highlight.go", "code.tex", "latexmkrc",
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tc.input))
+			if err != nil {
+				t.Fatalf("could not open input file: %+v", err)
+			}
+			defer f.Close()
+
+			// use an absolute cache directory, outside of the base directory.
+			cnv := Converter{Base: "testdata", CacheDir: t.TempDir()}
+			w := new(bytes.Buffer)
+			err = cnv.Bundle(context.Background(), w, f, tc.input, tc.format)
+			if err != nil {
+				t.Fatalf("could not bundle document: %+v", err)
+			}
+
+			files := readBundle(t, w.Bytes(), tc.format)
+			var names []string
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.want) {
+				t.Fatalf("invalid bundle content:\ngot= %q\nwant=%q", names, tc.want)
+			}
+
+			tex := files[strings.TrimSuffix(tc.input, ".slide")+".tex"]
+			for _, m := range includeRE.FindAllStringSubmatch(tex, -1) {
+				if _, ok := files[m[1]]; !ok {
+					t.Errorf("missing included file %q from bundle", m[1])
+				}
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake LaTeX engine requires a POSIX shell")
+	}
+
+	fake, err := filepath.Abs("testdata/_build/fake-latex")
+	if err != nil {
+		t.Fatalf("could not locate fake LaTeX engine: %+v", err)
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		f, err := os.Open("testdata/code.slide")
+		if err != nil {
+			t.Fatalf("could not open input file: %+v", err)
+		}
+		defer f.Close()
+
+		cnv := Converter{Base: "testdata", CacheDir: testCacheDir, BuildCmd: fake}
+		w := new(bytes.Buffer)
+		err = cnv.Build(context.Background(), w, f, "code.slide")
+		if err != nil {
+			t.Fatalf("could not build document: %+v", err)
+		}
+
+		const want = "%PDF-fake\n" +
+			"args: -interaction=nonstopmode -halt-on-error -file-line-error -shell-escape code.tex\n" +
+			"run\nrun\nrun\n"
+		if got := w.String(); got != want {
+			t.Fatalf("invalid PDF document:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		const slide = `Title
+
+Author
+
+* Fine
+
+Some text.
+
+* Broken
+
+Some FAKE-ERROR text.
+`
+		cnv := Converter{CacheDir: testCacheDir, BuildCmd: fake}
+		err := cnv.Build(context.Background(), io.Discard, strings.NewReader(slide), "talk.slide")
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+
+		var berr *BuildError
+		if !errors.As(err, &berr) {
+			t.Fatalf("invalid error type %T: %+v", err, err)
+		}
+		if len(berr.Errs) != 1 {
+			t.Fatalf("invalid number of LaTeX errors: got=%d, want=1\n%+v", len(berr.Errs), err)
+		}
+		got := berr.Errs[0]
+		if got.Slide != 3 || got.Title != "Broken" || got.Msg != "Undefined control sequence." {
+			t.Fatalf("invalid LaTeX error: %+v", got)
+		}
+
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Fatalf("invalid error type %T: %+v", err, err)
+		}
+		if perr.File != "talk.slide" || perr.Line != 9 || perr.Slide != "Broken" {
+			t.Fatalf("invalid error location: %+v", perr)
+		}
+	})
+}
+
+var includeRE = regexp.MustCompile(`\\includegraphics(?:\[[^]]*\])?\{([^}]+)\}`)
+
+// readBundle returns the content of the files in the provided archive.
+func readBundle(t *testing.T, raw []byte, format string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	switch format {
+	case Zip:
+		r, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+		if err != nil {
+			t.Fatalf("could not open zip archive: %+v", err)
+		}
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("could not open %q: %+v", f.Name, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("could not read %q: %+v", f.Name, err)
+			}
+			files[f.Name] = string(data)
+		}
+	default:
+		var r io.Reader = bytes.NewReader(raw)
+		if format == TarGz {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("could not open gzip stream: %+v", err)
+			}
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("could not read tar archive: %+v", err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("could not read %q: %+v", hdr.Name, err)
+			}
+			files[hdr.Name] = string(data)
+		}
+	}
+	return files
+}
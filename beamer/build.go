@@ -0,0 +1,303 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+// maxBuildRuns is the maximum number of LaTeX engine runs of a build.
+const maxBuildRuns = 5
+
+// auxExts lists the extensions of the auxiliary files whose content
+// must be stable for cross-references to be resolved.
+var auxExts = []string{".aux", ".nav", ".out", ".snm", ".toc"}
+
+// Build reads a present document named name from r, compiles the
+// corresponding LaTeX/Beamer document with the BuildCmd LaTeX command
+// and writes the resulting PDF document to w.
+//
+// The document and the files it references are written to a temporary
+// build directory, removed once the build is done.
+// The LaTeX engine is run until cross-references are resolved, while
+// latexmk is run once.
+// Errors reported by the LaTeX engine are returned as a *BuildError,
+// wrapped in an *Error locating the first one in the input document.
+func (c Converter) Build(ctx context.Context, w io.Writer, r io.Reader, name string) error {
+	err := c.init()
+	if err != nil {
+		return err
+	}
+	c.bundle = true
+
+	dir, err := os.MkdirTemp("", "present-tex-build-")
+	if err != nil {
+		return fmt.Errorf("could not create build directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	stem, err := c.writeBundle(ctx, &dirArchiver{dir: dir}, r, name)
+	if err != nil {
+		return err
+	}
+
+	cmd := c.BuildCmd
+	if cmd == "" {
+		cmd = c.Engine
+	}
+	// latexmk reads the engine and its options from latexmkrc, and
+	// runs it until cross-references are resolved.
+	latexmk := strings.TrimSuffix(filepath.Base(cmd), ".exe") == "latexmk"
+	args := []string{"-interaction=nonstopmode", "-halt-on-error", "-file-line-error"}
+	if c.shellEscape() && !latexmk {
+		args = append(args, "-shell-escape")
+	}
+	args = append(args, stem+".tex")
+
+	aux := auxFiles(dir, stem)
+	for i := 0; i < maxBuildRuns; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		run := exec.CommandContext(ctx, cmd, args...)
+		run.Dir = dir
+		out, err := run.CombinedOutput()
+		if err != nil {
+			return c.buildError(dir, stem, out, err)
+		}
+		if latexmk {
+			break
+		}
+		prev := aux
+		aux = auxFiles(dir, stem)
+		if aux == prev && !needsRerun(dir, stem) {
+			break
+		}
+	}
+
+	pdf, err := os.ReadFile(filepath.Join(dir, stem+".pdf"))
+	if err != nil {
+		return fmt.Errorf("could not read PDF document: %w", err)
+	}
+
+	_, err = w.Write(pdf)
+	if err != nil {
+		return fmt.Errorf("could not write PDF document: %w", err)
+	}
+
+	return nil
+}
+
+// auxFiles returns the concatenated content of the auxiliary files of
+// the named LaTeX document.
+func auxFiles(dir, stem string) string {
+	var o strings.Builder
+	for _, ext := range auxExts {
+		raw, err := os.ReadFile(filepath.Join(dir, stem+ext))
+		if err != nil {
+			continue
+		}
+		o.WriteString(ext)
+		o.Write(raw)
+	}
+	return o.String()
+}
+
+// needsRerun returns whether the log of the named LaTeX document asks
+// for another run.
+func needsRerun(dir, stem string) bool {
+	raw, err := os.ReadFile(filepath.Join(dir, stem+".log"))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(raw, []byte("Rerun to get")) ||
+		bytes.Contains(raw, []byte("Label(s) may have changed"))
+}
+
+// BuildError is the error returned by Build when the LaTeX engine fails.
+type BuildError struct {
+	Errs []TeXError // errors reported in the log of the LaTeX engine
+	Err  error      // error of the LaTeX engine command
+	Out  string     // output of the LaTeX engine command, when no error could be located
+}
+
+func (e *BuildError) Error() string {
+	var o strings.Builder
+	fmt.Fprintf(&o, "could not build PDF document: %v", e.Err)
+	for _, err := range e.Errs {
+		fmt.Fprintf(&o, "\n%v", err)
+	}
+	if len(e.Errs) == 0 && e.Out != "" {
+		fmt.Fprintf(&o, "\n%s", e.Out)
+	}
+	return o.String()
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// TeXError is an error reported by the LaTeX engine.
+type TeXError struct {
+	Line  int    // line of the error in the LaTeX document, or 0 if unknown
+	Slide int    // number of the slide holding the error, or 0 if unknown
+	Title string // title of the slide holding the error
+	Msg   string // error message
+}
+
+func (e TeXError) Error() string {
+	switch {
+	case e.Slide > 0 && e.Title != "":
+		return fmt.Sprintf("slide %d (%s): line %d: %s", e.Slide, e.Title, e.Line, e.Msg)
+	case e.Slide > 0:
+		return fmt.Sprintf("slide %d: line %d: %s", e.Slide, e.Line, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	default:
+		return e.Msg
+	}
+}
+
+// buildError returns the error of a failed LaTeX engine run, with the
+// errors of its log mapped to the slides of the document.
+// The error is located in the input document at the slide of its first
+// LaTeX error, when the frames of the LaTeX document are the ones of the
+// default template.
+func (c *Converter) buildError(dir, stem string, out []byte, err error) error {
+	berr := &BuildError{Err: err}
+	log, lerr := os.ReadFile(filepath.Join(dir, stem+".log"))
+	if lerr != nil {
+		log = out
+	}
+	berr.Errs = texErrors(log, stem+".tex")
+	if len(berr.Errs) == 0 {
+		berr.Out = lastLines(string(out), 20)
+	}
+
+	tex, terr := os.ReadFile(filepath.Join(dir, stem+".tex"))
+	if terr != nil {
+		return berr
+	}
+	slides := texSlides(tex)
+	for i, e := range berr.Errs {
+		if e.Line <= 0 {
+			continue
+		}
+		for j := len(slides) - 1; j >= 0; j-- {
+			if slides[j].line <= e.Line {
+				berr.Errs[i].Slide = j + 1
+				berr.Errs[i].Title = slides[j].title
+				break
+			}
+		}
+	}
+
+	if c.src == nil || len(slides) != len(c.frames) {
+		return berr
+	}
+	for _, e := range berr.Errs {
+		if e.Slide > 0 {
+			return c.src.errorf(c.frames[e.Slide-1], "%w", berr)
+		}
+	}
+	return berr
+}
+
+// frameLines returns the lines in the input document of the frames of
+// the LaTeX document, as rendered by the default template: the title
+// page, then a frame per slide.
+func (c *Converter) frameLines(doc *present.Doc) []int {
+	lines := []int{c.src.title()}
+	for _, s := range doc.Sections {
+		if !isHeading(s) || c.SectionFrames {
+			lines = append(lines, c.sections[sectionKey(s)])
+		}
+	}
+	return lines
+}
+
+var (
+	// texFileLineErrRE matches errors reported with -file-line-error.
+	texFileLineErrRE = regexp.MustCompile(`^(?:\./)?(.+\.tex):(\d+): (.*)$`)
+	// texLineRE matches the line number of a TeX error context.
+	texLineRE = regexp.MustCompile(`^l\.(\d+)`)
+)
+
+// texErrors returns the errors of the provided LaTeX log, for the named
+// LaTeX document.
+func texErrors(log []byte, name string) []TeXError {
+	var (
+		errs []TeXError
+		cur  = -1 // index of the last error without a line number
+		sc   = bufio.NewScanner(bytes.NewReader(log))
+	)
+	for sc.Scan() {
+		txt := sc.Text()
+		switch {
+		case texFileLineErrRE.MatchString(txt):
+			m := texFileLineErrRE.FindStringSubmatch(txt)
+			line := 0
+			if filepath.Base(m[1]) == name {
+				line, _ = strconv.Atoi(m[2])
+			}
+			errs = append(errs, TeXError{Line: line, Msg: m[3]})
+			cur = -1
+		case strings.HasPrefix(txt, "! "):
+			errs = append(errs, TeXError{Msg: strings.TrimPrefix(txt, "! ")})
+			cur = len(errs) - 1
+		case cur >= 0 && texLineRE.MatchString(txt):
+			errs[cur].Line, _ = strconv.Atoi(texLineRE.FindStringSubmatch(txt)[1])
+			cur = -1
+		}
+	}
+	return errs
+}
+
+// texSlide is a slide of a LaTeX/Beamer document.
+type texSlide struct {
+	line  int    // line of the beginning of the slide
+	title string // title of the slide
+}
+
+var texFrameTitleRE = regexp.MustCompile(`^\\frametitle\{(.*)\}$`)
+
+// texSlides returns the slides of the provided LaTeX/Beamer document.
+func texSlides(tex []byte) []texSlide {
+	var (
+		slides []texSlide
+		sc     = bufio.NewScanner(bytes.NewReader(tex))
+	)
+	for i := 1; sc.Scan(); i++ {
+		txt := sc.Text()
+		switch {
+		case strings.HasPrefix(txt, `\frame{\titlepage`):
+			slides = append(slides, texSlide{line: i, title: "title page"})
+		case strings.HasPrefix(txt, `\begin{frame}`):
+			slides = append(slides, texSlide{line: i})
+		case len(slides) > 0 && slides[len(slides)-1].title == "" && texFrameTitleRE.MatchString(txt):
+			slides[len(slides)-1].title = texFrameTitleRE.FindStringSubmatch(txt)[1]
+		}
+	}
+	return slides
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sbinet/present-tex/latex"
+)
+
+// Bundle formats.
+const (
+	Zip   = "zip"    // zip archive
+	Tar   = "tar"    // tar archive
+	TarGz = "tar.gz" // gzip-compressed tar archive
+)
+
+// BundleFormat returns the bundle format matching the extension of
+// the named archive file.
+func BundleFormat(name string) (string, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	}
+	return "", fmt.Errorf("unknown bundle format for %q", name)
+}
+
+// asset is a file referenced by the converted document.
+type asset struct {
+	src  string // name of the file, relative to the base directory, or path to a converted file
+	conv bool   // whether src is a converted file
+	name string // name of the file in the LaTeX document
+}
+
+// asset records the file name, referenced by the document, and returns
+// the name of the file to use in the LaTeX document.
+// path is either name or the path to the converted version of name.
+//
+// When bundling, names are relative to the root of the bundle and files
+// outside of the base directory (e.g. converted images) are moved under
+// an _assets directory of the bundle.
+// Otherwise, names are relative to the output directory.
+func (c *Converter) asset(name, path string) string {
+	c.file(name)
+	a := asset{src: name}
+	if path != name {
+		a = asset{src: path, conv: true}
+	}
+	for _, v := range c.assets {
+		if v.src == a.src && v.conv == a.conv {
+			return v.name
+		}
+	}
+	switch {
+	case c.bundle:
+		a.name = c.bundleName(a.src)
+	case a.conv && filepath.IsAbs(a.src):
+		// converted files in a user-wide cache directory.
+		a.name = filepath.ToSlash(a.src)
+	case a.conv:
+		a.name = c.outPath(a.src)
+	default:
+		a.name = c.outPath(c.path(a.src))
+	}
+	c.assets = append(c.assets, a)
+	return a.name
+}
+
+// bundleName returns a name, relative to the root of the bundle and not
+// already taken by another asset, for the provided file name.
+func (c *Converter) bundleName(name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		name = path.Join("_assets", path.Base(name))
+	}
+
+	var (
+		ext  = path.Ext(name)
+		stem = strings.TrimSuffix(name, ext)
+	)
+	for i := 1; c.hasAsset(name); i++ {
+		name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	return name
+}
+
+func (c *Converter) hasAsset(name string) bool {
+	for _, a := range c.assets {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Bundle reads a present document named name from r and writes to w
+// an archive, in the provided format, holding the corresponding
+// LaTeX/Beamer document, all the files it references and the
+// Makefile and latexmkrc files to build it.
+// Paths in the LaTeX document are relative to the root of the archive.
+func (c Converter) Bundle(ctx context.Context, w io.Writer, r io.Reader, name, format string) error {
+	err := c.init()
+	if err != nil {
+		return err
+	}
+	c.bundle = true
+
+	arch, err := newArchiver(w, format)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.writeBundle(ctx, arch, r, name)
+	if err != nil {
+		return err
+	}
+
+	err = arch.Close()
+	if err != nil {
+		return fmt.Errorf("could not close bundle: %w", err)
+	}
+
+	return nil
+}
+
+// writeBundle converts the present document named name read from r and
+// adds the LaTeX document, its assets, the Makefile and the latexmkrc
+// files to arch.
+// writeBundle returns the name of the LaTeX document, without extension.
+func (c *Converter) writeBundle(ctx context.Context, arch archiver, r io.Reader, name string) (string, error) {
+	tex := new(bytes.Buffer)
+	err := c.convert(ctx, tex, r, name)
+	if err != nil {
+		return "", err
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if stem == "" || stem == "." || stem == "stdin" {
+		stem = "slides"
+	}
+
+	err = arch.add(stem+".tex", tex.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("could not add LaTeX document to bundle: %w", err)
+	}
+
+	for _, a := range c.assets {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		var raw []byte
+		switch {
+		case a.conv:
+			raw, err = os.ReadFile(a.src)
+		default:
+			raw, err = c.readFile(a.src)
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not read bundle asset: %w", err)
+		}
+		err = arch.add(a.name, raw)
+		if err != nil {
+			return "", fmt.Errorf("could not add %q to bundle: %w", a.name, err)
+		}
+	}
+
+	cmd := c.Engine
+	if c.shellEscape() {
+		cmd += " -shell-escape"
+	}
+
+	err = arch.add("Makefile", []byte(fmt.Sprintf(makefile, stem, cmd)))
+	if err != nil {
+		return "", fmt.Errorf("could not add Makefile to bundle: %w", err)
+	}
+
+	err = arch.add("latexmkrc", []byte(fmt.Sprintf(latexmkrc, latexmkModes[c.Engine], c.Engine, cmd)))
+	if err != nil {
+		return "", fmt.Errorf("could not add latexmkrc to bundle: %w", err)
+	}
+
+	return stem, nil
+}
+
+// shellEscape returns whether the converted document needs the LaTeX
+// engine to be run with -shell-escape.
+func (c *Converter) shellEscape() bool {
+	return c.hasCode && c.CodeBackend == latex.Minted
+}
+
+const makefile = `## generated by present-tex.
+
+NAME  := %[1]s
+LATEX := %[2]s
+
+all: $(NAME).pdf
+
+$(NAME).pdf: $(NAME).tex
+	$(LATEX) $(NAME).tex
+	$(LATEX) $(NAME).tex
+
+clean:
+	rm -f $(NAME).aux $(NAME).log $(NAME).nav $(NAME).out $(NAME).snm $(NAME).toc $(NAME).vrb
+	rm -rf _minted-$(NAME)
+
+.PHONY: all clean
+`
+
+const latexmkrc = `# generated by present-tex.
+$pdf_mode = %d;
+$%s = '%s %%O %%S';
+`
+
+// latexmkModes maps LaTeX engines to latexmk PDF modes.
+var latexmkModes = map[string]int{
+	latex.PDFLaTeX: 1,
+	latex.XeLaTeX:  5,
+	latex.LuaLaTeX: 4,
+}
+
+// archiver writes files to an archive.
+type archiver interface {
+	add(name string, content []byte) error
+	Close() error
+}
+
+func newArchiver(w io.Writer, format string) (archiver, error) {
+	switch format {
+	case Zip:
+		return &zipArchiver{zip.NewWriter(w)}, nil
+	case Tar:
+		return &tarArchiver{w: tar.NewWriter(w)}, nil
+	case TarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchiver{w: tar.NewWriter(gz), gz: gz}, nil
+	default:
+		return nil, fmt.Errorf("invalid bundle format %q", format)
+	}
+}
+
+type zipArchiver struct {
+	w *zip.Writer
+}
+
+func (arch *zipArchiver) add(name string, content []byte) error {
+	f, err := arch.w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func (arch *zipArchiver) Close() error {
+	return arch.w.Close()
+}
+
+// dirArchiver writes files under a directory.
+type dirArchiver struct {
+	dir string
+}
+
+func (arch *dirArchiver) add(name string, content []byte) error {
+	fname := filepath.Join(arch.dir, filepath.FromSlash(name))
+	err := os.MkdirAll(filepath.Dir(fname), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fname, content, 0644)
+}
+
+func (arch *dirArchiver) Close() error { return nil }
+
+type tarArchiver struct {
+	w  *tar.Writer
+	gz *gzip.Writer
+}
+
+func (arch *tarArchiver) add(name string, content []byte) error {
+	err := arch.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = arch.w.Write(content)
+	return err
+}
+
+func (arch *tarArchiver) Close() error {
+	err := arch.w.Close()
+	if err != nil {
+		return err
+	}
+	if arch.gz != nil {
+		return arch.gz.Close()
+	}
+	return nil
+}
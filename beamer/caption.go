@@ -0,0 +1,47 @@
+// Copyright 2015 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"golang.org/x/tools/present"
+)
+
+// parseCaptions removes the captions from the document: captions
+// following an image are rendered with it, others are dropped.
+func (c *Converter) parseCaptions(doc *present.Doc) {
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		var captions []int
+		for j := range section.Elem {
+			elem := section.Elem[j]
+			switch elem := elem.(type) {
+			default:
+				continue
+			case present.Caption:
+				if _, ok := prevElem(section.Elem, j).(Image); !ok {
+					c.warnf(c.src.elem(i, elem.Cmd), "caption %q not following an image dropped", elem.Text)
+				}
+				captions = append(captions, j)
+			}
+		}
+		for j := len(captions) - 1; j >= 0; j-- {
+			idx := captions[j]
+			section.Elem = append(section.Elem[:idx], section.Elem[idx+1:]...)
+		}
+	}
+}
+
+// prevElem returns the element before the j-th one, or nil.
+func prevElem(elems []present.Elem, j int) present.Elem {
+	if j == 0 {
+		return nil
+	}
+	return elems[j-1]
+}
+
+func (c *Converter) parseCaption(elem *present.Caption) {
+	elem.Text = renderFont(elem.Text)
+	elem.Text = c.transliterate(elem.Text)
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sbinet/present-tex/latex"
+	"golang.org/x/tools/present"
+)
+
+// Code is a present code snippet, with its display options.
+type Code struct {
+	present.Code
+	Numbers   bool  // whether to display line numbers
+	FirstLine int   // number of the first line of the snippet in its file
+	Highlight []int // lines to highlight, as displayed
+}
+
+// write writes the code snippet to w, using the named code backend.
+func (c Code) write(w io.Writer, backend string) error {
+	return latex.WriteCode(w, backend, latex.Code{
+		Lang:      c.Ext,
+		Source:    c.Raw,
+		Numbers:   c.Numbers,
+		FirstLine: c.FirstLine,
+		Highlight: c.Highlight,
+	})
+}
+
+var (
+	highlightRE = regexp.MustCompile(`\s+HL([a-zA-Z0-9_]+)?$`)
+	hlCommentRE = regexp.MustCompile(`(.+) // HL(.*)$`)
+	numbersRE   = regexp.MustCompile(`^\.(code|play)\s+(?:-edit\s+)*-numbers\s`)
+	lineNumRE   = regexp.MustCompile(`<span num="([0-9]+)">`)
+)
+
+func (c *Converter) parseCode(doc *present.Doc) {
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		for ii, elem := range section.Elem {
+			switch elem := elem.(type) {
+			default:
+				continue
+			case present.Code:
+				c.hasCode = true
+				switch strings.ToLower(elem.Ext) {
+				case ".h":
+					elem.Ext = ".c"
+				case ".cxx", ".hxx", ".cc", ".hh":
+					elem.Ext = ".cpp"
+				case ".f", ".f77", ".f90":
+					elem.Ext = ".fortran"
+				case ".txt":
+					elem.Ext = "sh"
+				}
+				if elem.Ext != "" && !latex.KnownLang(c.CodeBackend, elem.Ext) {
+					c.warnf(c.src.elem(i, elem.Cmd), "unknown code extension %q for the %s code backend", elem.Ext, c.CodeBackend)
+				}
+				section.Elem[ii] = newCode(elem)
+			}
+		}
+	}
+}
+
+// newCode extracts the display options of a present code snippet and
+// removes the highlighting markers from its content.
+func newCode(elem present.Code) Code {
+	code := Code{
+		Code:      elem,
+		Numbers:   numbersRE.MatchString(elem.Cmd),
+		FirstLine: 1,
+	}
+
+	if m := lineNumRE.FindStringSubmatch(string(elem.Text)); m != nil {
+		code.FirstLine, _ = strconv.Atoi(m[1])
+	}
+
+	// Pull off the HL, if any, from the end of the command line.
+	highlight := ""
+	if m := highlightRE.FindStringSubmatch(elem.Cmd); m != nil {
+		highlight = m[1]
+	}
+
+	var (
+		raw   = bytes.TrimSuffix(elem.Raw, []byte("\n"))
+		lines = strings.Split(string(raw), "\n")
+		buf   = new(bytes.Buffer)
+	)
+	for i, line := range lines {
+		// Highlight lines that end with "// HL[highlight]"
+		// and strip the magic comment.
+		if m := hlCommentRE.FindStringSubmatch(line); m != nil {
+			line = m[1]
+			if m[2] == highlight {
+				n := i + 1
+				if code.Numbers {
+					n += code.FirstLine - 1
+				}
+				code.Highlight = append(code.Highlight, n)
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	code.Raw = buf.Bytes()
+
+	return code
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+func init() {
+	present.Register("column", parseColumn)
+	present.Register("endcolumn", parseEndColumn)
+}
+
+// Column is a column of a multi-column slide layout, holding the
+// elements between a '.column width' and a '.endcolumn' directive.
+type Column struct {
+	Cmd   string         // original command from present source
+	Width float64        // width of the column, as a fraction of the text width
+	Elem  []present.Elem // elements of the column
+}
+
+func (s Column) PresentCmd() string { return s.Cmd }
+func (Column) TemplateName() string { return "column" }
+
+func parseColumn(ctx *present.Context, fileName string, lineno int, text string) (present.Elem, error) {
+	args := strings.Fields(text)
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s:%d: invalid .column args", fileName, lineno)
+	}
+	width, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || width <= 0 || width > 1 {
+		return nil, fmt.Errorf("%s:%d: invalid .column width %q (want a fraction in (0,1])", fileName, lineno, args[1])
+	}
+	return Column{Cmd: text, Width: width}, nil
+}
+
+// endColumn marks the end of a column.
+type endColumn struct {
+	Cmd string // original command from present source
+}
+
+func (s endColumn) PresentCmd() string { return s.Cmd }
+func (endColumn) TemplateName() string { return "endcolumn" }
+
+func parseEndColumn(ctx *present.Context, fileName string, lineno int, text string) (present.Elem, error) {
+	if args := strings.Fields(text); len(args) != 1 {
+		return nil, fmt.Errorf("%s:%d: invalid .endcolumn args", fileName, lineno)
+	}
+	return endColumn{Cmd: text}, nil
+}
+
+// Columns is a multi-column slide layout, made of consecutive columns.
+type Columns struct {
+	Cols []Column // columns of the layout
+}
+
+func (Columns) PresentCmd() string   { return ".column" }
+func (Columns) TemplateName() string { return "columns" }
+
+var (
+	_ present.Elem = (*Column)(nil)
+	_ present.Elem = (*endColumn)(nil)
+	_ present.Elem = (*Columns)(nil)
+)
+
+// parseColumns gathers the elements between '.column' and '.endcolumn'
+// directives into columns, and consecutive columns into a Columns layout.
+func (c *Converter) parseColumns(doc *present.Doc) error {
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		var (
+			elems []present.Elem
+			cols  *Columns // current multi-column layout, if any
+			col   *Column  // current column, if any
+		)
+		for _, elem := range section.Elem {
+			switch elem := elem.(type) {
+			case Column:
+				if col != nil {
+					return c.src.errorf(c.src.elem(i, elem.Cmd), "nested %q directive", elem.Cmd)
+				}
+				if cols == nil {
+					elems = append(elems, Columns{})
+					cols = new(Columns)
+				}
+				col = &elem
+			case endColumn:
+				if col == nil {
+					return c.src.errorf(c.src.elem(i, elem.Cmd), "%q directive without .column", elem.Cmd)
+				}
+				cols.Cols = append(cols.Cols, *col)
+				elems[len(elems)-1] = *cols
+				col = nil
+			default:
+				switch {
+				case col != nil:
+					col.Elem = append(col.Elem, elem)
+				default:
+					cols = nil
+					elems = append(elems, elem)
+				}
+			}
+		}
+		if col != nil {
+			return c.src.errorf(c.src.elem(i, col.Cmd), "unterminated %q directive", col.Cmd)
+		}
+		section.Elem = elems
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"golang.org/x/tools/present"
+)
+
+// parseHTML reports the HTML fragments of the document, which are
+// typeset verbatim.
+func (c *Converter) parseHTML(doc *present.Doc) {
+	for i := range doc.Sections {
+		for _, elem := range doc.Sections[i].Elem {
+			if elem, ok := elem.(present.HTML); ok {
+				c.warnf(c.src.elem(i, elem.Cmd), "HTML fragment typeset verbatim")
+			}
+		}
+	}
+}
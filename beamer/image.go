@@ -0,0 +1,124 @@
+// Copyright 2015 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"fmt"
+
+	"github.com/sbinet/present-tex/latex"
+	"golang.org/x/tools/present"
+)
+
+type Image struct {
+	present.Image
+	HasCaption bool
+	Caption    present.Caption
+}
+
+func (c *Converter) parseImages(doc *present.Doc) error {
+	if c.Logo != "" {
+		img, err := c.image(c.Logo)
+		if err != nil {
+			return fmt.Errorf("could not parse logo %q: %w", c.Logo, err)
+		}
+		c.logo = c.asset(c.Logo, img.Name)
+	}
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		if bkg := background(*section); bkg != "" {
+			img, err := c.image(bkg)
+			if err != nil {
+				return c.src.errorf(c.src.elem(i, ".background "+bkg), "could not parse background image %q: %w", bkg, err)
+			}
+			setBackground(section, c.asset(bkg, img.Name))
+		}
+		for j := range section.Elem {
+			elem := section.Elem[j]
+			switch elem := elem.(type) {
+			default:
+				continue
+			case present.Image:
+				err := c.parseImage(&elem)
+				if err != nil {
+					return c.src.errorf(c.src.elem(i, elem.Cmd), "could not parse image %q: %w", elem.URL, err)
+				}
+				img := Image{Image: elem}
+				if j+1 < len(section.Elem) {
+					if elem, ok := section.Elem[j+1].(present.Caption); ok {
+						c.parseCaption(&elem)
+						img.HasCaption = true
+						img.Caption = elem
+					}
+				}
+				section.Elem[j] = img
+			}
+		}
+	}
+
+	c.parseCaptions(doc)
+	return nil
+}
+
+func (c *Converter) parseImage(elem *present.Image) error {
+	img, err := c.image(elem.URL)
+	if err != nil {
+		return err
+	}
+
+	if elem.Height == 0 || elem.Width == 0 {
+		if img.Width == 0 || img.Height == 0 {
+			return fmt.Errorf("could not infer dimensions of image file [%s]", elem.URL)
+		}
+		fitDims(elem, img.Width, img.Height)
+	}
+
+	elem.URL = c.asset(elem.URL, img.Name)
+
+	// rescale height/width to a (default=72) DPI resolution
+	// height and width are now in inches.
+	elem.Height /= c.DPI
+	elem.Width /= c.DPI
+
+	return nil
+}
+
+// image returns the named image file, converted to a format LaTeX can load.
+func (c *Converter) image(name string) (latex.Image, error) {
+	raw, err := c.readFile(name)
+	if err != nil {
+		return latex.Image{}, fmt.Errorf("error opening file [%s]: %w", name, err)
+	}
+	return c.images().Image(name, raw)
+}
+
+// images returns the transcoder converting images to formats LaTeX can load.
+func (c *Converter) images() latex.Transcoder {
+	return latex.Transcoder{
+		Dir:   c.CacheDir,
+		Lossy: c.LossyImages,
+		DPI:   c.DPI,
+		Warn: func(name, msg string) {
+			c.warnf(c.src.contains(name), "%s", msg)
+		},
+	}
+}
+
+// fitDims sets the missing dimensions of the image from the provided
+// width and height, keeping the aspect ratio.
+func fitDims(elem *present.Image, w, h int) {
+	switch {
+	case elem.Height == 0 && elem.Width == 0:
+		elem.Height = h
+		elem.Width = w
+	case elem.Height == 0 && elem.Width != 0:
+		// rescale, keeping ratio
+		ratio := float64(elem.Width) / float64(w)
+		elem.Height = int(float64(h) * ratio)
+	case elem.Height != 0 && elem.Width == 0:
+		// rescale, keeping ratio
+		ratio := float64(elem.Height) / float64(h)
+		elem.Width = int(float64(w) * ratio)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/present"
+)
+
+// langTag is the prefix of the document tag selecting the language of
+// the document (e.g. "Tags: lang:french".)
+const langTag = "lang:"
+
+// babelLangs maps common ISO 639-1 language codes to babel language names.
+var babelLangs = map[string]string{
+	"ca": "catalan",
+	"cs": "czech",
+	"da": "danish",
+	"de": "ngerman",
+	"el": "greek",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "magyar",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norsk",
+	"pl": "polish",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+	"uk": "ukrainian",
+}
+
+// babelLang returns the babel name of the provided language, given
+// either as a babel language name or as an ISO 639-1 code.
+func babelLang(lang string) (string, error) {
+	if name, ok := babelLangs[strings.ToLower(lang)]; ok {
+		return name, nil
+	}
+	if lang == "" {
+		return "", fmt.Errorf("invalid empty language")
+	}
+	for _, r := range lang {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		default:
+			return "", fmt.Errorf("invalid language %q", lang)
+		}
+	}
+	return lang, nil
+}
+
+// docLang returns the language set by the tags of the document, if any.
+func docLang(doc *present.Doc) string {
+	for _, tag := range doc.Tags {
+		if strings.HasPrefix(tag, langTag) {
+			return strings.TrimSpace(tag[len(langTag):])
+		}
+	}
+	return ""
+}
+
+// setLang sets the language of the converter from the tags of the
+// document, unless it was explicitly set.
+func (c *Converter) setLang(doc *present.Doc) error {
+	lang := c.Lang
+	if lang == "" {
+		lang = docLang(doc)
+	}
+	if lang == "" {
+		lang = "english"
+	}
+	name, err := babelLang(lang)
+	if err != nil {
+		return err
+	}
+	c.Lang = name
+	return nil
+}
+
+// texDate returns the LaTeX code typesetting t with babel's \today,
+// localized for the language of the document.
+func texDate(t time.Time) string {
+	return fmt.Sprintf(`\day=%d\relax\month=%d\relax\year=%d\relax\today`, t.Day(), t.Month(), t.Year())
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+// Video is a present video, with an optional poster frame.
+type Video struct {
+	present.Video
+	Poster string // path to a poster image, if any
+}
+
+func (c *Converter) parseMedia(doc *present.Doc) {
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		for j := range section.Elem {
+			switch elem := section.Elem[j].(type) {
+			default:
+				continue
+			case present.Video:
+				c.hasVideo = true
+				elem.Height /= c.DPI
+				elem.Width /= c.DPI
+				poster := c.findPoster(elem.URL)
+				if poster != "" {
+					poster = c.asset(poster, poster)
+				}
+				switch {
+				case strings.Contains(elem.URL, "://"):
+					// remote video.
+				case c.exists(elem.URL):
+					elem.URL = c.asset(elem.URL, elem.URL)
+				default:
+					c.warnf(c.src.elem(i, elem.Cmd), "video %q not found", elem.URL)
+					elem.URL = c.outPath(c.path(elem.URL))
+				}
+				section.Elem[j] = Video{
+					Video:  elem,
+					Poster: poster,
+				}
+			}
+		}
+	}
+}
+
+// findPoster returns the path to an image sitting next to the provided
+// local video file, with the same base name.
+func (c *Converter) findPoster(url string) string {
+	if strings.Contains(url, "://") {
+		return ""
+	}
+	base := strings.TrimSuffix(url, path.Ext(url))
+	for _, ext := range []string{".png", ".jpg", ".jpeg", ".pdf"} {
+		fname := base + ext
+		if c.exists(fname) {
+			return fname
+		}
+	}
+	return ""
+}
+
+const backgroundPrefix = "background-image: url('"
+
+// background returns the URL of the background image of a section,
+// as set by the '.background' present directive.
+func background(s present.Section) string {
+	for _, style := range s.Styles {
+		if !strings.HasPrefix(style, backgroundPrefix) {
+			continue
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(style, backgroundPrefix), "')")
+	}
+	return ""
+}
+
+// setBackground sets the URL of the background image of a section.
+func setBackground(s *present.Section, url string) {
+	for i, style := range s.Styles {
+		if strings.HasPrefix(style, backgroundPrefix) {
+			s.Styles[i] = backgroundPrefix + url + "')"
+		}
+	}
+}
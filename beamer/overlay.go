@@ -0,0 +1,55 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+func init() {
+	present.Register("pause", parsePause)
+	present.Register("incremental", parseIncremental)
+}
+
+// Pause is a present element stopping the current slide until the next
+// overlay, as set by the '.pause' directive.
+type Pause struct {
+	Cmd string // original command from present source
+}
+
+func (s Pause) PresentCmd() string { return s.Cmd }
+func (Pause) TemplateName() string { return "pause" }
+
+func parsePause(ctx *present.Context, fileName string, lineno int, text string) (present.Elem, error) {
+	if args := strings.Fields(text); len(args) != 1 {
+		return nil, fmt.Errorf("%s:%d: invalid .pause args", fileName, lineno)
+	}
+	return Pause{Cmd: text}, nil
+}
+
+// Incremental is a present element revealing the items of the lists
+// that follow it on the slide one by one, as set by the '.incremental'
+// directive.
+type Incremental struct {
+	Cmd string // original command from present source
+}
+
+func (s Incremental) PresentCmd() string { return s.Cmd }
+func (Incremental) TemplateName() string { return "incremental" }
+
+func parseIncremental(ctx *present.Context, fileName string, lineno int, text string) (present.Elem, error) {
+	if args := strings.Fields(text); len(args) != 1 {
+		return nil, fmt.Errorf("%s:%d: invalid .incremental args", fileName, lineno)
+	}
+	return Incremental{Cmd: text}, nil
+}
+
+var (
+	_ present.Elem = (*Pause)(nil)
+	_ present.Elem = (*Incremental)(nil)
+)
@@ -0,0 +1,280 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+// Error is a conversion error located in the input document.
+//
+// Its message has the "file:line: message" form editors can jump to.
+type Error struct {
+	File  string // name of the input document
+	Line  int    // line in the input document, or 0 if unknown
+	Slide string // title of the slide holding the error, if any
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return located(e.File, e.Line, e.Slide, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Warning is a conversion warning, about content of the input document
+// that was dropped or degraded in the LaTeX document.
+type Warning struct {
+	File  string // name of the input document
+	Line  int    // line in the input document, or 0 if unknown
+	Slide string // title of the slide holding the content, if any
+	Msg   string
+}
+
+func (w Warning) String() string {
+	return located(w.File, w.Line, w.Slide, w.Msg)
+}
+
+// located returns the provided message, in the "file:line: message" form.
+func located(file string, line int, slide, msg string) string {
+	var o strings.Builder
+	if file != "" {
+		o.WriteString(file)
+		if line > 0 {
+			fmt.Fprintf(&o, ":%d", line)
+		}
+		o.WriteString(": ")
+	}
+	if slide != "" {
+		fmt.Fprintf(&o, "slide %q: ", slide)
+	}
+	o.WriteString(msg)
+	return o.String()
+}
+
+// wrapError adds context to err, unless it wraps an error located in
+// the input document, which is then returned.
+func wrapError(err error, msg string) error {
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// source is an input document, used to locate diagnostics.
+type source struct {
+	name     string
+	lines    []string
+	headings []heading // section headings, in document order
+	block    int       // line from which the next Markdown block is looked up
+}
+
+// heading is a section heading of an input document.
+type heading struct {
+	line  int
+	title string
+}
+
+func newSource(name string, raw []byte) *source {
+	src := &source{
+		name:  name,
+		lines: strings.Split(string(raw), "\n"),
+		block: 1,
+	}
+
+	// as in present, Markdown documents start with a "# " title line.
+	prefix := "*"
+	for _, line := range src.lines {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			prefix = "##"
+		}
+		break
+	}
+
+	fenced := false
+	for i, line := range src.lines {
+		if prefix == "##" {
+			trim := strings.TrimSpace(line)
+			if strings.HasPrefix(trim, "```") || strings.HasPrefix(trim, "~~~") {
+				fenced = !fenced
+			}
+			if fenced {
+				continue
+			}
+		}
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		title := strings.TrimLeft(line, prefix[:1])
+		if title != "" && title[0] != ' ' {
+			continue
+		}
+		title = strings.TrimSpace(title)
+		if j := strings.LastIndex(title, "{#"); prefix == "##" && j >= 0 && strings.HasSuffix(title, "}") {
+			title = strings.TrimSpace(title[:j])
+		}
+		src.headings = append(src.headings, heading{line: i + 1, title: title})
+	}
+	if len(src.headings) > 0 {
+		src.block = src.headings[0].line
+	}
+	return src
+}
+
+// errorf returns an error located at the provided line.
+func (src *source) errorf(line int, format string, args ...interface{}) error {
+	return &Error{
+		File:  src.name,
+		Line:  line,
+		Slide: src.slide(line),
+		Err:   fmt.Errorf(format, args...),
+	}
+}
+
+// title returns the line of the title of the document, or 0.
+func (src *source) title() int {
+	for i, line := range src.lines {
+		if strings.TrimSpace(line) != "" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// slide returns the title of the slide holding the provided line.
+func (src *source) slide(line int) string {
+	for i := len(src.headings) - 1; i >= 0; i-- {
+		if src.headings[i].line <= line {
+			return src.headings[i].title
+		}
+	}
+	return ""
+}
+
+// section returns the line of the heading of the i-th section, in
+// document order, or 0.
+func (src *source) section(i int) int {
+	if i < 0 || i >= len(src.headings) {
+		return 0
+	}
+	return src.headings[i].line
+}
+
+// elem returns the line of the directive cmd in the i-th section, or the
+// line of the heading of that section if cmd could not be found.
+func (src *source) elem(i int, cmd string) int {
+	beg := src.section(i)
+	end := len(src.lines)
+	if i+1 < len(src.headings) {
+		end = src.headings[i+1].line - 1
+	}
+	if line := src.find(beg, end, cmd); line > 0 {
+		return line
+	}
+	return beg
+}
+
+// find returns the first line, between beg and end, with the provided
+// text, or 0.
+func (src *source) find(beg, end int, text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+	if beg < 1 {
+		beg = 1
+	}
+	for i := beg; i <= end && i <= len(src.lines); i++ {
+		switch line := strings.TrimSpace(src.lines[i-1]); line {
+		case text, `\` + text:
+			return i
+		}
+	}
+	return 0
+}
+
+// elemLine returns the first line of the provided element, or 0.
+func (src *source) elemLine(e present.Elem) int {
+	var text string
+	switch e := e.(type) {
+	case interface{ PresentCmd() string }:
+		text = e.PresentCmd()
+	case present.Text:
+		if len(e.Lines) > 0 {
+			text = e.Lines[0]
+		}
+	case present.List:
+		if len(e.Bullet) > 0 {
+			text = "- " + e.Bullet[0]
+		}
+	}
+	return src.find(1, len(src.lines), text)
+}
+
+// markdown returns the line of the provided Markdown block.
+// Markdown blocks are looked up in document order.
+func (src *source) markdown(block []byte) int {
+	first := ""
+	for _, line := range strings.Split(string(block), "\n") {
+		if strings.TrimSpace(line) != "" {
+			first = line
+			break
+		}
+	}
+	line := src.find(src.block, len(src.lines), first)
+	if line > 0 {
+		src.block = line + 1
+	}
+	return line
+}
+
+// sectionKey returns a key identifying the provided section.
+func sectionKey(s present.Section) string {
+	return fmt.Sprint(s.Number)
+}
+
+// contains returns the first line holding the provided text, or 0.
+func (src *source) contains(text string) int {
+	for i, line := range src.lines {
+		if strings.Contains(line, text) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// presentErrRE matches errors of the present parser, located with a
+// "file:line" prefix.
+var presentErrRE = regexp.MustCompile(`^(.*?):(\d+):? (.*)$`)
+
+// parseError returns the provided error of the present parser, located
+// in the input document.
+func (src *source) parseError(err error) error {
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr
+	}
+	m := presentErrRE.FindStringSubmatch(err.Error())
+	if m == nil || m[1] != src.name {
+		return &Error{File: src.name, Err: err}
+	}
+	line, _ := strconv.Atoi(m[2])
+	return &Error{
+		File:  src.name,
+		Line:  line,
+		Slide: src.slide(line),
+		Err:   errors.New(m[3]),
+	}
+}
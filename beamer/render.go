@@ -0,0 +1,122 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sbinet/present-tex/latex"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/tools/present"
+)
+
+func (c *Converter) renderAsLaTeX(input []byte) (present.Elem, error) {
+	line := c.src.markdown(input)
+	r := latex.New(c.DPI,
+		latex.WithReadFile(c.readFile),
+		latex.WithTranscoder(c.images()),
+		latex.WithCodeBackend(c.CodeBackend),
+		latex.WithEngine(c.Engine),
+		latex.WithAssets(c.asset),
+		latex.WithUnmapped(c.unmapped),
+		latex.WithWarn(func(n int, msg string) {
+			if line == 0 {
+				c.warnf(0, "%s", msg)
+				return
+			}
+			// line is the one of the first non-blank line of input.
+			c.warnf(line+n-1-leadingBlankLines(input), "%s", msg)
+		}),
+	)
+	md := goldmark.New(
+		goldmark.WithRenderer(r),
+		goldmark.WithExtensions(extension.GFM, extension.Footnote, latex.MathExtension),
+	)
+	reader := text.NewReader(input)
+	doc := md.Parser().Parse(reader)
+	err := fixupMarkdown(doc)
+	if err != nil {
+		return nil, c.src.errorf(line, "%w", err)
+	}
+
+	var b strings.Builder
+	if err := md.Renderer().Render(&b, input, doc); err != nil {
+		return nil, c.src.errorf(line, "could not render Markdown: %w", err)
+	}
+	c.hasCode = c.hasCode || r.HasCode()
+	c.hasTable = c.hasTable || r.HasTable()
+	c.hasStrike = c.hasStrike || r.HasStrikethrough()
+	c.hasTaskList = c.hasTaskList || r.HasTaskList()
+	return Latex{Latex: b.String()}, nil
+}
+
+// leadingBlankLines returns the number of blank lines at the beginning
+// of the provided text.
+func leadingBlankLines(text []byte) int {
+	n := 0
+	for _, line := range bytes.Split(text, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) != 0 {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func fixupMarkdown(n ast.Node) error {
+	err := ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			switch n := n.(type) {
+			case *ast.Link:
+				n.SetAttributeString("target", []byte("_blank"))
+				// https://developers.google.com/web/tools/lighthouse/audits/noopener
+				n.SetAttributeString("rel", []byte("noopener"))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not fixup markdown: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	present.Register("latex", parseLatex)
+}
+
+// parseLatex parses the '.latex file.tex' directive, which includes
+// raw LaTeX content from a file.
+func parseLatex(ctx *present.Context, fileName string, lineno int, text string) (present.Elem, error) {
+	args := strings.Fields(text)
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s:%d: invalid .latex args", fileName, lineno)
+	}
+	name := filepath.Join(filepath.Dir(fileName), args[1])
+	raw, err := ctx.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", fileName, lineno, err)
+	}
+	return Latex{Cmd: text, Latex: string(raw)}, nil
+}
+
+// Latex is a present element holding raw LaTeX content.
+type Latex struct {
+	Cmd   string // original command from present source
+	Latex string
+}
+
+func (s Latex) PresentCmd() string { return s.Cmd }
+func (Latex) TemplateName() string { return "latex" }
+
+var _ present.Elem = (*Latex)(nil)
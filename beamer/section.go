@@ -0,0 +1,70 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"golang.org/x/tools/present"
+)
+
+// flattenSections moves nested sections (e.g. from '**' or '###' headings)
+// out of their parent section, right after it, so each section of the
+// document is rendered either as a frame or as a structural heading.
+func flattenSections(doc *present.Doc) {
+	var out []present.Section
+	var flatten func(s present.Section)
+	flatten = func(s present.Section) {
+		var (
+			elems = make([]present.Elem, 0, len(s.Elem))
+			subs  []present.Section
+		)
+		for _, e := range s.Elem {
+			switch e := e.(type) {
+			case present.Section:
+				subs = append(subs, e)
+			default:
+				elems = append(elems, e)
+			}
+		}
+		s.Elem = elems
+		out = append(out, s)
+		for _, sub := range subs {
+			flatten(sub)
+		}
+	}
+	for _, s := range doc.Sections {
+		flatten(s)
+	}
+	doc.Sections = out
+}
+
+// isHeading returns whether the section is a structural heading, ie:
+// a titled section without content.
+func isHeading(s present.Section) bool {
+	return len(s.Elem) == 0 && s.Title != ""
+}
+
+// hasHeadings returns whether any of the provided sections is a
+// structural heading.
+func hasHeadings(sections []present.Section) bool {
+	for _, s := range sections {
+		if isHeading(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionCmd returns the LaTeX sectioning command associated with the
+// nesting level of the provided section.
+func sectionCmd(s present.Section) string {
+	switch len(s.Number) {
+	case 0, 1:
+		return "section"
+	case 2:
+		return "subsection"
+	default:
+		return "subsubsection"
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	msg := "hello" // HLmsg
+	fmt.Println(msg) // HLprint
+	fmt.Println("world") // HLprint
+}
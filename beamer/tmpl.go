@@ -0,0 +1,305 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beamer
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"strings"
+
+	"github.com/sbinet/present-tex/latex"
+	"golang.org/x/tools/present"
+)
+
+//go:embed templates
+var tmplFS embed.FS
+
+func defaultTemplates() fs.FS {
+	o, err := fs.Sub(tmplFS, "templates")
+	if err != nil {
+		panic(fmt.Errorf("could not locate embedded 'templates' directory: %+v", err))
+	}
+	return o
+}
+
+var (
+	// tex1 escapes LaTeX special characters, except for underscores
+	// that may be present font indicators, escaped by tex2 once the
+	// font indicators were rendered.
+	tex1 = latex.NewEscaper("_")
+	tex2 = strings.NewReplacer(
+		"_", `\_`,
+	)
+)
+
+// transliterate replaces the non-ASCII runes of s with their LaTeX
+// equivalent, for LaTeX engines without native Unicode support.
+func (c *Converter) transliterate(s string) string {
+	if latex.Unicode(c.Engine) {
+		return s
+	}
+	return latex.Transliterate(s, c.unmapped)
+}
+
+// escape escapes LaTeX special characters, without interpreting
+// font indicators.
+func (c *Converter) escape(s string) string {
+	return c.transliterate(tex2.Replace(tex1.Replace(s)))
+}
+
+// escapeURL escapes characters that would break a URL passed as an
+// argument to a LaTeX command.
+func escapeURL(s string) string {
+	return urlRepl.Replace(s)
+}
+
+var urlRepl = strings.NewReplacer(
+	"#", `\#`,
+	"%", `\%`,
+)
+
+// style escapes s and turns its present font indicators into LaTeX syntax.
+// Math spans (\(...\) and \[...\]) are left untouched.
+func (c *Converter) style(s string) string {
+	var o strings.Builder
+	for s != "" {
+		beg, end := mathSpan(s)
+		if beg < 0 {
+			o.WriteString(c.styleText(s))
+			break
+		}
+		o.WriteString(c.styleText(s[:beg]))
+		o.WriteString(s[beg:end])
+		s = s[end:]
+	}
+	return o.String()
+}
+
+func (c *Converter) styleText(s string) string {
+	s = tex1.Replace(s)
+	s = string(renderStyle(s))
+	s = tex2.Replace(s)
+	s = c.transliterate(s)
+	return s
+}
+
+// mathSpan returns the boundaries of the first math span of s,
+// delimited by \(...\) or \[...\], or -1 if there is none.
+func mathSpan(s string) (beg, end int) {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '\\' {
+			continue
+		}
+		var close string
+		switch s[i+1] {
+		case '(':
+			close = `\)`
+		case '[':
+			close = `\]`
+		default:
+			continue
+		}
+		j := strings.Index(s[i+2:], close)
+		if j < 0 {
+			return -1, -1
+		}
+		return i, i + 2 + j + len(close)
+	}
+	return -1, -1
+}
+
+// funcs returns the template functions for the current conversion.
+func (c *Converter) funcs() template.FuncMap {
+	return template.FuncMap{
+		"elem":            c.renderElem,
+		"stringFromBytes": func(raw []byte) string { return string(raw) },
+		"join":            func(lines []string) string { return strings.Join(lines, "\n") },
+		"nodot": func(s string) string {
+			if strings.HasPrefix(s, ".") {
+				return s[1:]
+			}
+			return s
+		},
+		"style":  c.style,
+		"escape": c.escape,
+		"url":    escapeURL,
+		"beamerTheme": func() string {
+			return c.Theme
+		},
+		"hasCode": func() bool {
+			return c.hasCode
+		},
+		"notesOption": func() string {
+			return notesOptions[c.Notes]
+		},
+		"codeBackend": func() string {
+			return c.CodeBackend
+		},
+		"engine": func() string {
+			return c.Engine
+		},
+		"unicodeEngine": func() bool {
+			return latex.Unicode(c.Engine)
+		},
+		"mainFont": func() string {
+			return c.MainFont
+		},
+		"monoFont": func() string {
+			return c.MonoFont
+		},
+		"cjkFont": func() string {
+			return c.CJKFont
+		},
+		"lang": func() string {
+			return c.Lang
+		},
+		"colorTheme": func() string {
+			return c.ColorTheme
+		},
+		"fontTheme": func() string {
+			return c.FontTheme
+		},
+		"aspectRatio": func() string {
+			return c.AspectRatio
+		},
+		"logo": func() string {
+			return c.logo
+		},
+		"preamble": func() string {
+			return c.Preamble
+		},
+		"texDate": texDate,
+		"code": func(code Code) (string, error) {
+			o := new(strings.Builder)
+			err := code.write(o, c.CodeBackend)
+			return o.String(), err
+		},
+		"hasVideo": func() bool {
+			return c.hasVideo
+		},
+		"videoMode": func() string {
+			return c.Video
+		},
+		"hasTable": func() bool {
+			return c.hasTable
+		},
+		"hasStrikethrough": func() bool {
+			return c.hasStrike
+		},
+		"hasTaskList": func() bool {
+			return c.hasTaskList
+		},
+		"hasAMSSymb": func() bool {
+			return c.hasAMSSymb
+		},
+		"background": background,
+		"sectionFrames": func() bool {
+			return c.SectionFrames
+		},
+		"isHeading":   isHeading,
+		"hasHeadings": hasHeadings,
+		"sectionCmd":  sectionCmd,
+		"pdfAuthor":   c.pdfAuthor,
+		"texAuthor":   c.texAuthor,
+	}
+}
+
+func (c *Converter) pdfAuthor(authors []present.Author) string {
+	out := make([]string, 0, len(authors))
+	for _, a := range authors {
+		name, _, _ := parseAuthor(a)
+		if name == "" {
+			continue
+		}
+		out = append(out, fmt.Sprintf("pdfauthor={%s},%%\n", c.style(name)))
+	}
+	return strings.Join(out, "  ")
+}
+
+func (c *Converter) texAuthor(authors []present.Author) (string, error) {
+	const hdr = "\\parbox{0.26\\textwidth}{\n\t\\texorpdfstring\n\t  {\n\t\t\\centering\n"
+	out := make([]string, 0, len(authors))
+	shorts := make([]string, 0, len(authors))
+	for _, a := range authors {
+		elems, err := c.renderAuthor(a)
+		if err != nil {
+			return "", err
+		}
+		if len(elems) == 0 {
+			continue
+		}
+		name := elems[0]
+		if name == "" {
+			continue
+		}
+		if len(shorts) > 0 {
+			shorts = append(shorts, "\\&")
+		}
+		shorts = append(shorts, name)
+		if len(out) > 0 {
+			out = append(out, "\\and %\n")
+		}
+		out = append(out, hdr)
+		for _, elem := range elems {
+			out = append(out, "\t\t"+elem+` \\`+"\n")
+		}
+		out = append(out, "\t  }\n\t{"+elems[0]+"}\n}\n")
+	}
+	if len(out) > 0 {
+		out = append([]string{"\\author[" + strings.Join(shorts, " ") + "]{\n"}, out...)
+		out = append(out, "}\n")
+	}
+	return strings.Join(out, " "), nil
+}
+
+func (c *Converter) renderAuthor(author present.Author) ([]string, error) {
+	var elems []string
+	if len(author.Elem) == 0 {
+		return elems, nil
+	}
+	for _, e := range author.Elem {
+		str, err := c.renderElem(c.tmpl, e)
+		if err != nil {
+			return nil, fmt.Errorf("could not render author: %w", err)
+		}
+		elem := html.UnescapeString(string(str))
+		elem = strings.Trim(elem, "\n")
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+func parseAuthor(author present.Author) (name string, inst string, mail present.Link) {
+	elems := author.TextElem()
+	if len(elems) == 0 {
+		return
+	}
+	getLines := func(i int) []string {
+		lines := elems[i].(present.Text).Lines
+		return lines
+	}
+
+	name = strings.TrimSpace(getLines(0)[0])
+	if name == "" {
+		return
+	}
+
+	if len(elems) > 1 {
+		inst = strings.TrimSpace(getLines(1)[0])
+	}
+	for _, elem := range author.Elem {
+		link, ok := elem.(present.Link)
+		if !ok {
+			continue
+		}
+		if strings.Contains(link.Label, "@") {
+			mail = link
+		}
+	}
+	return
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// configName is the name of the configuration files of present documents.
+const configName = "present-tex.toml"
+
+// config holds the settings of present documents, read from
+// configuration files.
+//
+// Paths are resolved against the directory of the configuration file.
+type config struct {
+	Theme       string `toml:"theme"`
+	ColorTheme  string `toml:"color-theme"`
+	FontTheme   string `toml:"font-theme"`
+	AspectRatio string `toml:"aspect-ratio"`
+	DPI         int    `toml:"dpi"`
+	Templates   string `toml:"templates"` // directory holding the slide templates
+	Engine      string `toml:"engine"`
+	CodeBackend string `toml:"code-backend"`
+	Lang        string `toml:"lang"`
+	Logo        string `toml:"logo"`
+	Preamble    string `toml:"preamble"`
+
+	// Files holds the settings of documents, keyed by their path
+	// relative to the directory of the configuration file.
+	// They take precedence over the other settings of the file.
+	Files map[string]config `toml:"files"`
+}
+
+// loadConfig returns the settings of the named present document, read
+// from the configuration files of its directory and of its parent
+// directories, and the names of these files.
+// Settings of the nearest files take precedence.
+func loadConfig(input string) (config, []string, error) {
+	var (
+		cfg   config
+		names []string
+	)
+	doc, err := filepath.Abs(input)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(doc); ; {
+		dirs = append(dirs, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		name := filepath.Join(dir, configName)
+		var file config
+		md, err := toml.DecodeFile(name, &file)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return cfg, names, fmt.Errorf("could not read configuration file %q: %w", name, err)
+		}
+		names = append(names, name)
+		if keys := md.Undecoded(); len(keys) > 0 {
+			return cfg, names, fmt.Errorf("configuration file %q: unknown settings %q", name, keys)
+		}
+
+		cfg.merge(file.resolve(dir))
+		rel, err := filepath.Rel(dir, doc)
+		if err != nil {
+			continue
+		}
+		if sub, ok := file.Files[filepath.ToSlash(rel)]; ok {
+			cfg.merge(sub.resolve(dir))
+		}
+	}
+	return cfg, names, nil
+}
+
+// resolve returns the settings with their paths resolved against dir.
+func (cfg config) resolve(dir string) config {
+	if cfg.Templates != "" && !filepath.IsAbs(cfg.Templates) {
+		cfg.Templates = filepath.Join(dir, cfg.Templates)
+	}
+	if cfg.Logo != "" && !filepath.IsAbs(cfg.Logo) {
+		cfg.Logo = filepath.Join(dir, cfg.Logo)
+	}
+	return cfg
+}
+
+// merge sets the settings of cfg that are set in o.
+func (cfg *config) merge(o config) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&cfg.Theme, o.Theme)
+	set(&cfg.ColorTheme, o.ColorTheme)
+	set(&cfg.FontTheme, o.FontTheme)
+	set(&cfg.AspectRatio, o.AspectRatio)
+	set(&cfg.Templates, o.Templates)
+	set(&cfg.Engine, o.Engine)
+	set(&cfg.CodeBackend, o.CodeBackend)
+	set(&cfg.Lang, o.Lang)
+	set(&cfg.Logo, o.Logo)
+	set(&cfg.Preamble, o.Preamble)
+	if o.DPI > 0 {
+		cfg.DPI = o.DPI
+	}
+}
+
+// configure returns the converter of the named input document, with the
+// settings of its configuration files that were not set by command-line
+// flags, the directory holding its slide templates, if any, and the
+// names of the files these settings depend on: its configuration files
+// and slide template.
+//
+// Documents read from the standard input ("stdin") are located in the
+// -root directory.
+func configure(cnv beamer.Converter, input string) (beamer.Converter, string, []string, error) {
+	if input == "stdin" {
+		input = filepath.Join(cnv.Base, input)
+	}
+	cfg, names, err := loadConfig(input)
+	if err != nil {
+		return cnv, "", names, err
+	}
+
+	flags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { flags[f.Name] = true })
+	set := func(name string, dst *string, v string) {
+		if v != "" && !flags[name] {
+			*dst = v
+		}
+	}
+	set("beamer-theme", &cnv.Theme, cfg.Theme)
+	set("color-theme", &cnv.ColorTheme, cfg.ColorTheme)
+	set("font-theme", &cnv.FontTheme, cfg.FontTheme)
+	set("aspect-ratio", &cnv.AspectRatio, cfg.AspectRatio)
+	set("engine", &cnv.Engine, cfg.Engine)
+	set("code-backend", &cnv.CodeBackend, cfg.CodeBackend)
+	set("lang", &cnv.Lang, cfg.Lang)
+	if cfg.Preamble != "" {
+		cnv.Preamble = cfg.Preamble
+	}
+	if cfg.DPI > 0 && !flags["dpi"] {
+		cnv.DPI = cfg.DPI
+	}
+	tmpl := cfg.Templates
+	switch {
+	case flags["base"]:
+		tmpl = flag.Lookup("base").Value.String()
+	case tmpl != "":
+		cnv.Templates = os.DirFS(tmpl)
+	}
+	if tmpl != "" {
+		names = append(names, filepath.Join(tmpl, "beamer.tmpl"))
+	}
+	if cfg.Logo != "" && !flags["logo"] {
+		// the logo is resolved against the base directory of the document.
+		base := cnv.Base
+		if base == "" {
+			base = filepath.Dir(input)
+		}
+		cnv.Logo = cfg.Logo
+		if abs, err := filepath.Abs(base); err == nil {
+			if rel, err := filepath.Rel(abs, cfg.Logo); err == nil && !strings.HasPrefix(rel, "..") {
+				cnv.Logo = rel
+			}
+		}
+	}
+	return cnv, tmpl, names, nil
+}
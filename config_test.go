@@ -0,0 +1,175 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+func TestConfigure(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string // configuration files, by directory
+		input string
+		args  []string // command-line flags, with $DIR the test directory
+		want  beamer.Converter
+	}{
+		{
+			name:  "defaults",
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "default", Engine: "pdflatex", DPI: 72},
+		},
+		{
+			name: "parent",
+			files: map[string]string{
+				".": `theme = "Madrid"` + "\n" + `dpi = 300`,
+			},
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "Madrid", Engine: "pdflatex", DPI: 300},
+		},
+		{
+			name: "nearest",
+			files: map[string]string{
+				".":     `theme = "Madrid"` + "\n" + `engine = "xelatex"`,
+				"talks": `theme = "Berkeley"`,
+			},
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "Berkeley", Engine: "xelatex", DPI: 72},
+		},
+		{
+			name: "files",
+			files: map[string]string{
+				".":     `theme = "Madrid"`,
+				"talks": `theme = "Berkeley"` + "\n" + `[files."a.slide"]` + "\n" + `theme = "Warsaw"`,
+			},
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "Warsaw", Engine: "pdflatex", DPI: 72},
+		},
+		{
+			name: "files-other-document",
+			files: map[string]string{
+				"talks": `theme = "Berkeley"` + "\n" + `[files."a.slide"]` + "\n" + `theme = "Warsaw"`,
+			},
+			input: "talks/b.slide",
+			want:  beamer.Converter{Theme: "Berkeley", Engine: "pdflatex", DPI: 72},
+		},
+		{
+			name: "files-of-parent",
+			files: map[string]string{
+				".":     `[files."talks/a.slide"]` + "\n" + `theme = "Warsaw"` + "\n" + `engine = "lualatex"`,
+				"talks": `theme = "Berkeley"`,
+			},
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "Berkeley", Engine: "lualatex", DPI: 72},
+		},
+		{
+			name: "flags",
+			files: map[string]string{
+				"talks": `theme = "Berkeley"` + "\n" + `dpi = 300` + "\n" + `engine = "xelatex"` + "\n" +
+					`[files."a.slide"]` + "\n" + `theme = "Warsaw"`,
+			},
+			input: "talks/a.slide",
+			args:  []string{"-beamer-theme=Madrid", "-dpi=96"},
+			want:  beamer.Converter{Theme: "Madrid", Engine: "xelatex", DPI: 96},
+		},
+		{
+			name: "flags-with-default-value",
+			files: map[string]string{
+				"talks": `theme = "Berkeley"` + "\n" + `engine = "xelatex"`,
+			},
+			input: "talks/a.slide",
+			args:  []string{"-beamer-theme=default"},
+			want:  beamer.Converter{Theme: "default", Engine: "xelatex", DPI: 72},
+		},
+		{
+			name: "stdin",
+			files: map[string]string{
+				"talks": `theme = "Berkeley"`,
+			},
+			input: "stdin",
+			args:  []string{"-root=$DIR/talks"},
+			want:  beamer.Converter{Theme: "Berkeley", Engine: "pdflatex", DPI: 72},
+		},
+		{
+			name: "logo",
+			files: map[string]string{
+				"talks": `logo = "_figs/logo.png"`,
+			},
+			input: "talks/a.slide",
+			want:  beamer.Converter{Theme: "default", Engine: "pdflatex", DPI: 72, Logo: "_figs/logo.png"},
+		},
+		{
+			name: "logo-flag",
+			files: map[string]string{
+				"talks": `logo = "_figs/logo.png"`,
+			},
+			input: "talks/a.slide",
+			args:  []string{"-logo=gopher.png"},
+			want:  beamer.Converter{Theme: "default", Engine: "pdflatex", DPI: 72, Logo: "gopher.png"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for sub, cfg := range tc.files {
+				name := filepath.Join(dir, sub, configName)
+				err := os.MkdirAll(filepath.Dir(name), 0755)
+				if err != nil {
+					t.Fatalf("could not create directory: %+v", err)
+				}
+				err = os.WriteFile(name, []byte(cfg), 0644)
+				if err != nil {
+					t.Fatalf("could not write configuration file: %+v", err)
+				}
+			}
+
+			defer func(fset *flag.FlagSet) { flag.CommandLine = fset }(flag.CommandLine)
+			flag.CommandLine = flag.NewFlagSet("present-tex", flag.ContinueOnError)
+
+			var cnv beamer.Converter
+			flag.StringVar(&cnv.Theme, "beamer-theme", "default", "")
+			flag.StringVar(&cnv.Engine, "engine", "pdflatex", "")
+			flag.StringVar(&cnv.Logo, "logo", "", "")
+			flag.IntVar(&cnv.DPI, "dpi", 72, "")
+			flag.StringVar(&cnv.Base, "root", "", "")
+			var args []string
+			for _, arg := range tc.args {
+				args = append(args, strings.ReplaceAll(arg, "$DIR", dir))
+			}
+			err := flag.CommandLine.Parse(args)
+			if err != nil {
+				t.Fatalf("could not parse flags: %+v", err)
+			}
+
+			input := tc.input
+			if input != "stdin" {
+				input = filepath.Join(dir, input)
+			}
+			got, _, _, err := configure(cnv, input)
+			if err != nil {
+				t.Fatalf("could not configure converter: %+v", err)
+			}
+
+			for _, v := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"theme", got.Theme, tc.want.Theme},
+				{"engine", got.Engine, tc.want.Engine},
+				{"dpi", got.DPI, tc.want.DPI},
+				{"logo", filepath.ToSlash(got.Logo), tc.want.Logo},
+			} {
+				if v.got != v.want {
+					t.Errorf("invalid %s: got=%v, want=%v", v.name, v.got, v.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// diagnostics collects the warnings and errors of conversions, and
+// reports them either in the "file:line: message" form editors can jump
+// to, or as JSON objects, one per line, for continuous integration tools.
+type diagnostics struct {
+	w      io.Writer
+	json   bool // whether diagnostics are reported as JSON objects
+	strict bool // whether warnings fail the conversion
+
+	mu    sync.Mutex // serializes reports of concurrent conversions
+	warns int        // number of warnings since the last reset
+}
+
+// diagnostic is the JSON form of a warning or an error.
+type diagnostic struct {
+	Severity string `json:"severity"` // "warning" or "error"
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Slide    string `json:"slide,omitempty"`
+	Message  string `json:"message"`
+}
+
+// warn reports a conversion warning.
+func (d *diagnostics) warn(w beamer.Warning) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.warns++
+	if d.json {
+		d.encode(diagnostic{
+			Severity: "warning",
+			File:     w.File,
+			Line:     w.Line,
+			Slide:    w.Slide,
+			Message:  w.Msg,
+		})
+		return
+	}
+	fmt.Fprintln(d.w, w)
+}
+
+// error reports err.
+// Errors located in the input document are reported on their own,
+// other errors are formatted with format.
+func (d *diagnostics) error(format string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var perr *beamer.Error
+	located := errors.As(err, &perr)
+	switch {
+	case d.json && located:
+		d.encode(diagnostic{
+			Severity: "error",
+			File:     perr.File,
+			Line:     perr.Line,
+			Slide:    perr.Slide,
+			Message:  perr.Err.Error(),
+		})
+	case d.json:
+		d.encode(diagnostic{Severity: "error", Message: err.Error()})
+	case located:
+		fmt.Fprintln(d.w, perr)
+	default:
+		log.Printf(format, err)
+	}
+}
+
+func (d *diagnostics) encode(diag diagnostic) {
+	err := json.NewEncoder(d.w).Encode(diag)
+	if err != nil {
+		log.Printf("could not encode diagnostic: %+v", err)
+	}
+}
+
+// summary reports the number of warnings since the last reset.
+// In strict mode, an error is returned when there were any.
+func (d *diagnostics) summary() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.warns == 0 {
+		return nil
+	}
+	if !d.json {
+		log.Printf("%d warning(s)", d.warns)
+	}
+	if d.strict {
+		return fmt.Errorf("%d warning(s) in strict mode", d.warns)
+	}
+	return nil
+}
+
+// check reports the number of warnings, and exits in strict mode when
+// there were any.
+func (d *diagnostics) check() {
+	err := d.summary()
+	if err != nil {
+		d.fatalf("%v", err)
+	}
+}
+
+// fatalf reports err and exits.
+func (d *diagnostics) fatalf(format string, err error) {
+	d.error(format, err)
+	os.Exit(1)
+}
+
+// usage reports err as a command-line usage error and exits.
+func (d *diagnostics) usage(err error) {
+	d.error("%v", err)
+	os.Exit(2)
+}
+
+// reset forgets about the warnings reported so far.
+func (d *diagnostics) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.warns = 0
+}
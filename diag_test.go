@@ -0,0 +1,121 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// report reports a warning, a located error and an error on d.
+func report(d *diagnostics) {
+	d.warn(beamer.Warning{File: "talk.slide", Line: 8, Slide: "Intro", Msg: "HTML fragment typeset verbatim"})
+	d.error("error: %v", fmt.Errorf("could not convert: %w", &beamer.Error{
+		File: "talk.slide", Line: 12, Slide: "Code", Err: fmt.Errorf("invalid .code args"),
+	}))
+	d.error("error: %v", fmt.Errorf("could not create output file"))
+}
+
+func TestDiagnosticsText(t *testing.T) {
+	var (
+		o    = new(bytes.Buffer)
+		logs = new(bytes.Buffer)
+		d    = &diagnostics{w: o}
+	)
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	report(d)
+	err := d.summary()
+	if err != nil {
+		t.Fatalf("could not summarize diagnostics: %+v", err)
+	}
+
+	want := `talk.slide:8: slide "Intro": HTML fragment typeset verbatim
+talk.slide:12: slide "Code": invalid .code args
+`
+	if got := o.String(); got != want {
+		t.Fatalf("invalid diagnostics:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	for _, want := range []string{"error: could not create output file", "1 warning(s)"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("missing %q in logs:\n%s", want, logs)
+		}
+	}
+}
+
+func TestDiagnosticsJSON(t *testing.T) {
+	var (
+		o = new(bytes.Buffer)
+		d = &diagnostics{w: o, json: true}
+	)
+
+	report(d)
+	err := d.summary()
+	if err != nil {
+		t.Fatalf("could not summarize diagnostics: %+v", err)
+	}
+
+	var got []diagnostic
+	dec := json.NewDecoder(o)
+	for dec.More() {
+		var diag diagnostic
+		err := dec.Decode(&diag)
+		if err != nil {
+			t.Fatalf("could not decode diagnostic: %+v", err)
+		}
+		got = append(got, diag)
+	}
+
+	want := []diagnostic{
+		{Severity: "warning", File: "talk.slide", Line: 8, Slide: "Intro", Message: "HTML fragment typeset verbatim"},
+		{Severity: "error", File: "talk.slide", Line: 12, Slide: "Code", Message: "invalid .code args"},
+		{Severity: "error", Message: "could not create output file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid diagnostics:\ngot= %+v\nwant=%+v", got, want)
+	}
+}
+
+func TestDiagnosticsStrict(t *testing.T) {
+	for _, tc := range []struct {
+		strict bool
+		warns  int
+		err    string
+	}{
+		{strict: false, warns: 0},
+		{strict: false, warns: 2},
+		{strict: true, warns: 0},
+		{strict: true, warns: 2, err: "2 warning(s) in strict mode"},
+	} {
+		t.Run(fmt.Sprintf("strict=%v-warns=%d", tc.strict, tc.warns), func(t *testing.T) {
+			d := &diagnostics{w: new(bytes.Buffer), json: true, strict: tc.strict}
+			for i := 0; i < tc.warns; i++ {
+				d.warn(beamer.Warning{Msg: "dropped"})
+			}
+			err := d.summary()
+			switch {
+			case err == nil && tc.err != "":
+				t.Fatalf("expected an error")
+			case err != nil && err.Error() != tc.err:
+				t.Fatalf("invalid error: got=%q, want=%q", err, tc.err)
+			}
+
+			d.reset()
+			err = d.summary()
+			if err != nil {
+				t.Fatalf("invalid error after reset: %+v", err)
+			}
+		})
+	}
+}
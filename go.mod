@@ -3,9 +3,15 @@ module github.com/sbinet/present-tex
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/yuin/goldmark v1.7.4
 	golang.org/x/image v0.18.0
+	golang.org/x/text v0.16.0
 	golang.org/x/tools v0.22.0
 )
 
+require golang.org/x/net v0.0.0-20211118161319-6a13c67c3ce4 // indirect
+
 replace golang.org/x/tools => github.com/sbinet-staging/tools v0.1.8-0.20211011121524-98b8e10c01db
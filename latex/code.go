@@ -0,0 +1,236 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Code backends, used to typeset source code.
+const (
+	Minted   = "minted"   // minted package (requires -shell-escape and Pygments)
+	Listings = "listings" // listings package
+	Native   = "native"   // pre-colored fancyvrb Verbatim environment
+)
+
+// Code is a snippet of source code.
+type Code struct {
+	Lang      string // language name or file extension (e.g. "go", ".py")
+	Source    []byte // content of the snippet
+	Numbers   bool   // whether to display line numbers
+	FirstLine int    // number of the first line of the snippet
+	Highlight []int  // lines to highlight, as displayed
+}
+
+// CodeBackends lists the supported code backends.
+var CodeBackends = []string{Minted, Listings, Native}
+
+// WriteCode writes the provided snippet of source code to w, using the
+// named code backend.
+func WriteCode(w io.Writer, backend string, code Code) error {
+	src := strings.TrimSuffix(string(code.Source), "\n")
+	lang := codeLang(code.Lang)
+
+	var err error
+	switch backend {
+	case Minted:
+		// minted (Pygments) knows about many more languages and aliases.
+		lang := strings.TrimPrefix(code.Lang, ".")
+		if lang == "" {
+			lang = "text"
+		}
+		_, err = fmt.Fprintf(w, "\\begin{minted}[%s]{%s}\n%s\n\\end{minted}\n",
+			strings.Join(code.mintedOptions(), ","), lang, src,
+		)
+	case Listings:
+		_, err = fmt.Fprintf(w, "\\begin{lstlisting}[%s]\n%s\n\\end{lstlisting}\n",
+			strings.Join(code.listingsOptions(lang), ","),
+			code.listingsSource(src),
+		)
+	case Native:
+		_, err = fmt.Fprintf(w, "\\begin{Verbatim}[%s]\n%s\n\\end{Verbatim}\n",
+			strings.Join(code.nativeOptions(), ","),
+			code.nativeSource(lang, src),
+		)
+	default:
+		return fmt.Errorf("latex: unknown code backend %q", backend)
+	}
+	return err
+}
+
+func (code Code) numbers() []string {
+	if !code.Numbers {
+		return nil
+	}
+	opts := []string{"numbers=left"}
+	if code.FirstLine > 1 {
+		opts = append(opts, "firstnumber="+strconv.Itoa(code.FirstLine))
+	}
+	return opts
+}
+
+// isHighlighted returns whether the i-th line (0-based) of the snippet
+// should be highlighted.
+func (code Code) isHighlighted(i int) bool {
+	n := i + 1
+	if code.Numbers && code.FirstLine > 1 {
+		n += code.FirstLine - 1
+	}
+	for _, v := range code.Highlight {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (code Code) mintedOptions() []string {
+	var opts []string
+	if code.Numbers {
+		opts = append(opts, "linenos")
+		if code.FirstLine > 1 {
+			opts = append(opts, "firstnumber="+strconv.Itoa(code.FirstLine))
+		}
+	}
+	if len(code.Highlight) > 0 {
+		lines := make([]string, len(code.Highlight))
+		for i, v := range code.Highlight {
+			lines[i] = strconv.Itoa(v)
+		}
+		opts = append(opts, "highlightlines={"+strings.Join(lines, ",")+"}")
+	}
+	return opts
+}
+
+// listingsLangs maps language names to listings languages.
+// The Go language is defined in the document preamble.
+var listingsLangs = map[string]string{
+	"go":      "Go",
+	"c":       "C",
+	"cpp":     "C++",
+	"python":  "Python",
+	"fortran": "Fortran",
+	"sh":      "bash",
+}
+
+func (code Code) listingsOptions(lang string) []string {
+	var opts []string
+	if v, ok := listingsLangs[lang]; ok {
+		opts = append(opts, "language="+v)
+	}
+	opts = append(opts, code.numbers()...)
+	if len(code.Highlight) > 0 {
+		opts = append(opts, "escapeinside={(*@}{@*)}")
+	}
+	return opts
+}
+
+func (code Code) listingsSource(src string) string {
+	if len(code.Highlight) == 0 {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if !code.isHighlighted(i) {
+			continue
+		}
+		text := strings.TrimLeft(line, " \t")
+		if text == "" {
+			continue
+		}
+		indent := line[:len(line)-len(text)]
+		lines[i] = indent + `(*@\textbf{` + codeRepl.Replace(text) + `}@*)`
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeRepl escapes LaTeX special characters in code.
+var codeRepl = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"$", `\$`,
+	"&", `\&`,
+	"%", `\%`,
+	"#", `\#`,
+	"_", `\_`,
+	"^", `\^{}`,
+	"~", `\~{}`,
+)
+
+func (code Code) nativeOptions() []string {
+	opts := []string{`commandchars=\\\{\}`}
+	opts = append(opts, code.numbers()...)
+	return opts
+}
+
+func (code Code) nativeSource(lang, src string) string {
+	lines := strings.Split(highlight(lang, src), "\n")
+	for i, line := range lines {
+		if !code.isHighlighted(i) {
+			continue
+		}
+		text := strings.TrimLeft(line, " \t")
+		if text == "" {
+			continue
+		}
+		indent := line[:len(line)-len(text)]
+		lines[i] = indent + `\textbf{` + text + `}`
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mintedLangs lists the Pygments lexers, and file extensions, of
+// commonly used languages.
+// Pygments knows about many more: languages not listed here are still
+// passed to minted, but reported as unknown.
+var mintedLangs = words(`go c cpp python fortran sh
+	asm awk bat c++ cc clj clojure cmake cs csharp css cu cuda d dart diff
+	docker dockerfile el elisp elixir erl erlang ex exs f90 gawk glsl
+	graphql groovy h haskell hcl hpp hs html ini java javascript jl js json
+	julia kotlin kt latex lisp lua m make makefile markdown matlab md ml
+	nim nix ocaml perl php pl powershell proto protobuf ps1 py r rb rs rst
+	ruby rust s scala scheme scm scss sql swift tcl tex text tf toml ts
+	typescript v verilog vhdl vim xml yaml yml zig`)
+
+// KnownLang returns whether the named code backend highlights the
+// provided language name or file extension.
+func KnownLang(backend, lang string) bool {
+	switch backend {
+	case Minted:
+		return mintedLangs[codeLang(lang)] || mintedLangs[strings.ToLower(strings.TrimPrefix(lang, "."))]
+	case Listings:
+		_, ok := listingsLangs[codeLang(lang)]
+		return ok
+	case Native:
+		_, ok := syntaxes[codeLang(lang)]
+		return ok
+	}
+	return false
+}
+
+// codeLang returns the canonical name of the provided language name or
+// file extension.
+func codeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimPrefix(lang, "."))
+	switch lang {
+	case "go", "golang":
+		return "go"
+	case "c", "h":
+		return "c"
+	case "cpp", "c++", "cxx", "cc", "hpp", "hxx", "hh":
+		return "cpp"
+	case "py", "python", "python3":
+		return "python"
+	case "f", "f77", "f90", "fortran":
+		return "fortran"
+	case "sh", "bash", "shell", "console":
+		return "sh"
+	}
+	return lang
+}
@@ -0,0 +1,21 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+// LaTeX engines.
+const (
+	PDFLaTeX = "pdflatex" // pdfTeX, with inputenc
+	XeLaTeX  = "xelatex"  // XeTeX, with native Unicode support
+	LuaLaTeX = "lualatex" // LuaTeX, with native Unicode support
+)
+
+// Engines lists the supported LaTeX engines.
+var Engines = []string{PDFLaTeX, XeLaTeX, LuaLaTeX}
+
+// Unicode returns whether the named LaTeX engine supports Unicode
+// input natively, so that non-ASCII text needs not be escaped.
+func Unicode(engine string) bool {
+	return engine == XeLaTeX || engine == LuaLaTeX
+}
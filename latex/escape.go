@@ -0,0 +1,381 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Transliterate returns s with its non-ASCII runes replaced by their
+// LaTeX equivalent, for LaTeX engines without native Unicode support
+// (e.g. pdflatex.)
+//
+// Latin-1, Latin Extended-A, Greek letters and common typographic and
+// mathematical symbols are supported.
+// Runes without a LaTeX equivalent are kept as is and passed to
+// unmapped, if not nil.
+func Transliterate(s string, unmapped func(r rune)) string {
+	if isASCII(s) {
+		return s
+	}
+
+	var o strings.Builder
+	o.Grow(len(s))
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			o.WriteRune(r)
+			continue
+		}
+		tex, ok := texRune(r)
+		if !ok {
+			if unmapped != nil {
+				unmapped(r)
+			}
+			o.WriteRune(r)
+			continue
+		}
+		o.WriteString(tex)
+	}
+	return o.String()
+}
+
+// UTF8 replaces UTF8 code sequences with their LaTeX equivalent.
+//
+// Deprecated: use Transliterate instead.
+func UTF8(s string) string {
+	return Transliterate(s, nil)
+}
+
+// UsesAMSSymb returns whether the transliteration of s uses commands of
+// the amssymb package.
+func UsesAMSSymb(s string) bool {
+	return strings.ContainsAny(s, amssymbRunes)
+}
+
+// amssymbRunes are the runes transliterated to commands of the amssymb package.
+const amssymbRunes = "□✓"
+
+// NewEscaper returns a replacer escaping the LaTeX special characters of
+// text, except for the ones listed in keep.
+// ASCII arrows and comparison operators are typeset as math symbols.
+func NewEscaper(keep ...string) *strings.Replacer {
+	oldnew := make([]string, 0, len(texSpecials))
+Loop:
+	for i := 0; i < len(texSpecials); i += 2 {
+		for _, k := range keep {
+			if texSpecials[i] == k {
+				continue Loop
+			}
+		}
+		oldnew = append(oldnew, texSpecials[i], texSpecials[i+1])
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// texSpecials lists the replacements of LaTeX special characters.
+// Comparisons are done in order: longer sequences come first.
+var texSpecials = []string{
+	"-->", `$\Rightarrow$`,
+	"<--", `$\Leftarrow$`,
+	"->", `$\rightarrow$`,
+	"<-", `$\leftarrow$`,
+	"=>", `$\Rightarrow$`,
+	">=", `$\geq$`,
+	"<=", `$\leq$`,
+	">", `$>$`,
+	"<", `$<$`,
+
+	`\`, `\textbackslash{}`,
+	"_", `\_`,
+	"&", `\&`,
+	"$", `\$`,
+	"^", `\^{}`,
+	"%", `\%`,
+	"~", `$\sim$`,
+	"#", `\#`,
+	"{", `\{`,
+	"}", `\}`,
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// texRune returns the LaTeX equivalent of the provided non-ASCII rune.
+func texRune(r rune) (string, bool) {
+	if tex, ok := texRunes[r]; ok {
+		return tex, true
+	}
+
+	// accented letters: a base ASCII letter, followed by combining accents.
+	d := norm.NFD.String(string(r))
+	base, n := utf8.DecodeRuneInString(d)
+	if base >= utf8.RuneSelf || len(d) == n {
+		return "", false
+	}
+	tex := string(base)
+	for _, acc := range d[n:] {
+		cmd, ok := texAccents[acc]
+		if !ok {
+			return "", false
+		}
+		switch {
+		case len(cmd) == 2 && !isLetter(cmd[1]) && len(tex) == 1:
+			// e.g. \'e
+			tex = cmd + tex
+		default:
+			// e.g. \v{s}
+			tex = cmd + "{" + tex + "}"
+		}
+	}
+	return tex, true
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// texAccents maps combining diacritical marks to LaTeX accent commands.
+var texAccents = map[rune]string{
+	'\u0300': "\\`", // grave
+	'\u0301': `\'`,  // acute
+	'\u0302': `\^`,  // circumflex
+	'\u0303': `\~`,  // tilde
+	'\u0304': `\=`,  // macron
+	'\u0306': `\u`,  // breve
+	'\u0307': `\.`,  // dot above
+	'\u0308': `\"`,  // diaeresis
+	'\u030a': `\r`,  // ring above
+	'\u030b': `\H`,  // double acute
+	'\u030c': `\v`,  // caron
+	'\u0323': `\d`,  // dot below
+	'\u0327': `\c`,  // cedilla
+	'\u0328': `\k`,  // ogonek
+	'\u0331': `\b`,  // macron below
+	'\u0326': `\c`,  // comma below, typeset as a cedilla
+}
+
+// texRunes maps non-ASCII runes that are not accented ASCII letters to
+// their LaTeX equivalent.
+var texRunes = map[rune]string{
+	// Latin-1 supplement.
+	'\u00a0': `~`,
+	'¡':      "!`",
+	'¢':      `\textcent{}`,
+	'£':      `\pounds{}`,
+	'¤':      `\textcurrency{}`,
+	'¥':      `\textyen{}`,
+	'¦':      `\textbrokenbar{}`,
+	'§':      `\S{}`,
+	'¨':      `\textasciidieresis{}`,
+	'©':      `\copyright{}`,
+	'ª':      `\textordfeminine{}`,
+	'«':      `\guillemotleft{}`,
+	'¬':      `$\neg$`,
+	'\u00ad': `\-`,
+	'®':      `\textregistered{}`,
+	'¯':      `\textasciimacron{}`,
+	'°':      `\textdegree{}`,
+	'±':      `$\pm$`,
+	'²':      `\textsuperscript{2}`,
+	'³':      `\textsuperscript{3}`,
+	'´':      `\textasciiacute{}`,
+	'µ':      `$\mu$`,
+	'¶':      `\P{}`,
+	'·':      `$\cdot$`,
+	'¸':      `\c{ }`,
+	'¹':      `\textsuperscript{1}`,
+	'º':      `\textordmasculine{}`,
+	'»':      `\guillemotright{}`,
+	'¼':      `\textonequarter{}`,
+	'½':      `\textonehalf{}`,
+	'¾':      `\textthreequarters{}`,
+	'¿':      "?`",
+	'Å':      `{\AA}`,
+	'Æ':      `{\AE}`,
+	'Ð':      `{\DH}`,
+	'×':      `$\times$`,
+	'Ø':      `{\O}`,
+	'Þ':      `{\TH}`,
+	'ß':      `{\ss}`,
+	'å':      `{\aa}`,
+	'æ':      `{\ae}`,
+	'ð':      `{\dh}`,
+	'÷':      `$\div$`,
+	'ø':      `{\o}`,
+	'þ':      `{\th}`,
+
+	// Latin Extended-A.
+	'Đ': `{\DJ}`,
+	'đ': `{\dj}`,
+	'ħ': `$\hbar$`,
+	'ı': `{\i}`,
+	'Ĳ': `IJ`,
+	'ĳ': `ij`,
+	'Ŀ': `L\textperiodcentered{}`,
+	'ŀ': `l\textperiodcentered{}`,
+	'Ł': `{\L}`,
+	'ł': `{\l}`,
+	'ŉ': `'n`,
+	'Ŋ': `{\NG}`,
+	'ŋ': `{\ng}`,
+	'Œ': `{\OE}`,
+	'œ': `{\oe}`,
+
+	// Greek.
+	'Α': `A`,
+	'Β': `B`,
+	'Γ': `$\Gamma$`,
+	'Δ': `$\Delta$`,
+	'Ε': `E`,
+	'Ζ': `Z`,
+	'Η': `H`,
+	'Θ': `$\Theta$`,
+	'Ι': `I`,
+	'Κ': `K`,
+	'Λ': `$\Lambda$`,
+	'Μ': `M`,
+	'Ν': `N`,
+	'Ξ': `$\Xi$`,
+	'Ο': `O`,
+	'Π': `$\Pi$`,
+	'Ρ': `P`,
+	'Σ': `$\Sigma$`,
+	'Τ': `T`,
+	'Υ': `$\Upsilon$`,
+	'Φ': `$\Phi$`,
+	'Χ': `X`,
+	'Ψ': `$\Psi$`,
+	'Ω': `$\Omega$`,
+	'α': `$\alpha$`,
+	'β': `$\beta$`,
+	'γ': `$\gamma$`,
+	'δ': `$\delta$`,
+	'ε': `$\varepsilon$`,
+	'ζ': `$\zeta$`,
+	'η': `$\eta$`,
+	'θ': `$\theta$`,
+	'ι': `$\iota$`,
+	'κ': `$\kappa$`,
+	'λ': `$\lambda$`,
+	'μ': `$\mu$`,
+	'ν': `$\nu$`,
+	'ξ': `$\xi$`,
+	'ο': `o`,
+	'π': `$\pi$`,
+	'ρ': `$\rho$`,
+	'ς': `$\varsigma$`,
+	'σ': `$\sigma$`,
+	'τ': `$\tau$`,
+	'υ': `$\upsilon$`,
+	'φ': `$\varphi$`,
+	'χ': `$\chi$`,
+	'ψ': `$\psi$`,
+	'ω': `$\omega$`,
+	'ϑ': `$\vartheta$`,
+	'ϕ': `$\phi$`,
+	'ϵ': `$\epsilon$`,
+
+	// punctuation.
+	'\u2002': `\enspace{}`,
+	'\u2003': `\quad{}`,
+	'\u2009': `\,`,
+	'\u200b': `\hspace{0pt}`,
+	'‐':      `-`,
+	'‑':      `\mbox{-}`,
+	'‒':      `--`,
+	'–':      `--`,
+	'—':      `---`,
+	'―':      `---`,
+	'‖':      `$\|$`,
+	'‘':      "`",
+	'’':      `'`,
+	'‚':      `,`,
+	'“':      "``",
+	'”':      `''`,
+	'„':      `,,`,
+	'†':      `\dag{}`,
+	'‡':      `\ddag{}`,
+	'•':      `\textbullet{}`,
+	'…':      `\ldots{}`,
+	'‰':      `\textperthousand{}`,
+	'′':      `$'$`,
+	'″':      `$''$`,
+	'‹':      `\guilsinglleft{}`,
+	'›':      `\guilsinglright{}`,
+
+	// letter-like symbols and currencies.
+	'€':      `\texteuro{}`,
+	'ℏ':      `$\hbar$`,
+	'ℓ':      `$\ell$`,
+	'№':      `\textnumero{}`,
+	'™':      `\texttrademark{}`,
+	'\u212b': `{\AA}`, // angstrom sign
+
+	// arrows.
+	'←': `$\leftarrow$`,
+	'↑': `$\uparrow$`,
+	'→': `$\rightarrow$`,
+	'↓': `$\downarrow$`,
+	'↔': `$\leftrightarrow$`,
+	'↦': `$\mapsto$`,
+	'⇐': `$\Leftarrow$`,
+	'⇒': `$\Rightarrow$`,
+	'⇔': `$\Leftrightarrow$`,
+
+	// mathematical operators.
+	'∀': `$\forall$`,
+	'∂': `$\partial$`,
+	'∃': `$\exists$`,
+	'∅': `$\emptyset$`,
+	'∇': `$\nabla$`,
+	'∈': `$\in$`,
+	'∉': `$\notin$`,
+	'∏': `$\prod$`,
+	'∑': `$\sum$`,
+	'−': `$-$`,
+	'∓': `$\mp$`,
+	'∗': `$\ast$`,
+	'∘': `$\circ$`,
+	'√': `$\surd$`,
+	'∝': `$\propto$`,
+	'∞': `$\infty$`,
+	'∧': `$\wedge$`,
+	'∨': `$\vee$`,
+	'∩': `$\cap$`,
+	'∪': `$\cup$`,
+	'∫': `$\int$`,
+	'∼': `$\sim$`,
+	'≃': `$\simeq$`,
+	'≅': `$\cong$`,
+	'≈': `$\approx$`,
+	'≠': `$\neq$`,
+	'≡': `$\equiv$`,
+	'≤': `$\leq$`,
+	'≥': `$\geq$`,
+	'≪': `$\ll$`,
+	'≫': `$\gg$`,
+	'⊂': `$\subset$`,
+	'⊃': `$\supset$`,
+	'⊆': `$\subseteq$`,
+	'⊇': `$\supseteq$`,
+	'⊕': `$\oplus$`,
+	'⊗': `$\otimes$`,
+	'⊥': `$\perp$`,
+	'⋅': `$\cdot$`,
+	'⋯': `$\cdots$`,
+
+	// miscellaneous symbols.
+	'□': `$\square$`,
+	'✓': `$\checkmark$`,
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	tast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// HasStrikethrough returns whether the rendered documents contained
+// strikethrough text.
+// Strikethrough text is typeset with the ulem package.
+func (r *Renderer) HasStrikethrough() bool { return r.hasStrike }
+
+// HasTaskList returns whether the rendered documents contained task lists.
+// Task list checkboxes are typeset with the amssymb package.
+func (r *Renderer) HasTaskList() bool { return r.hasTaskList }
+
+func (r *Renderer) renderStrikethrough(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		r.hasStrike = true
+		_, _ = w.WriteString("\\sout{")
+	} else {
+		_ = w.WriteByte('}')
+	}
+	return ast.WalkContinue, nil
+}
+
+// taskCheckBox returns the checkbox of a task list item, if any.
+func taskCheckBox(n ast.Node) *tast.TaskCheckBox {
+	fc := n.FirstChild()
+	if fc == nil {
+		return nil
+	}
+	box, _ := fc.FirstChild().(*tast.TaskCheckBox)
+	return box
+}
+
+func (r *Renderer) renderTaskCheckBox(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	// checkboxes are rendered as the label of their list item.
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderFootnoteLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*tast.FootnoteLink)
+	fn := footnote(n)
+	if fn == nil {
+		return ast.WalkContinue, nil
+	}
+
+	buf := new(bytes.Buffer)
+	bw := bufio.NewWriter(buf)
+	err := r.Render(bw, source, fn)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_ = bw.Flush()
+
+	_, _ = w.WriteString("\\footnote{")
+	_, _ = w.Write(bytes.TrimSpace(buf.Bytes()))
+	_ = w.WriteByte('}')
+	return ast.WalkContinue, nil
+}
+
+// footnote returns the footnote referenced by the provided link.
+func footnote(link *tast.FootnoteLink) *tast.Footnote {
+	var doc ast.Node = link
+	for doc.Parent() != nil {
+		doc = doc.Parent()
+	}
+	for c := doc.LastChild(); c != nil; c = c.PreviousSibling() {
+		list, ok := c.(*tast.FootnoteList)
+		if !ok {
+			continue
+		}
+		for fn := list.FirstChild(); fn != nil; fn = fn.NextSibling() {
+			if fn, ok := fn.(*tast.Footnote); ok && fn.Index == link.Index {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) renderFootnoteList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	// footnotes are rendered where they are referenced.
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) renderFootnoteBacklink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkSkipChildren, nil
+}
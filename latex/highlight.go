@@ -0,0 +1,316 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind describes the syntactic class of a token.
+// Token kinds are rendered with the 'code<kind>' colors defined
+// in the document preamble.
+type tokenKind string
+
+const (
+	tokText    tokenKind = ""
+	tokKeyword tokenKind = "keyword"
+	tokType    tokenKind = "type"
+	tokString  tokenKind = "string"
+	tokComment tokenKind = "comment"
+	tokNumber  tokenKind = "number"
+	tokPreproc tokenKind = "preproc"
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// syntax describes the lexical structure of a programming language.
+type syntax struct {
+	lineComments []string  // line comment markers
+	blockComment [2]string // block comment delimiters, if any
+	quotes       string    // string delimiters, honoring backslash escapes
+	rawQuotes    string    // raw string delimiters, which may span lines
+	tripleQuotes bool      // whether """ and ''' delimit (multi-line) strings
+	preproc      bool      // whether lines starting with '#' are preprocessor directives
+	wordComments bool      // whether line comments must start a new word
+	foldCase     bool      // whether keywords are case insensitive
+	keywords     map[string]bool
+	types        map[string]bool
+}
+
+func words(s string) map[string]bool {
+	o := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		o[w] = true
+	}
+	return o
+}
+
+var cKeywords = `auto break case const continue default do else enum extern
+	for goto if inline register restrict return sizeof static struct switch
+	typedef union volatile while`
+
+var cTypes = `bool char double float int long short signed unsigned void
+	size_t int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t
+	FILE NULL true false`
+
+var syntaxes = map[string]*syntax{
+	"go": {
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		rawQuotes:    "`",
+		keywords: words(`break case chan const continue default defer else
+			fallthrough for func go goto if import interface map package range
+			return select struct switch type var`),
+		types: words(`any bool byte comparable complex64 complex128 error
+			float32 float64 int int8 int16 int32 int64 rune string uint uint8
+			uint16 uint32 uint64 uintptr true false iota nil append cap clear
+			close complex copy delete imag len make max min new panic print
+			println real recover`),
+	},
+	"c": {
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		preproc:      true,
+		keywords:     words(cKeywords),
+		types:        words(cTypes),
+	},
+	"cpp": {
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		preproc:      true,
+		keywords: words(cKeywords + ` alignas alignof catch class constexpr
+			consteval constinit co_await co_return co_yield decltype delete
+			explicit export friend mutable namespace new noexcept operator
+			private protected public requires static_assert template this throw
+			try typeid typename using virtual override final`),
+		types: words(cTypes + ` auto nullptr std string vector map wchar_t
+			char8_t char16_t char32_t`),
+	},
+	"python": {
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		tripleQuotes: true,
+		keywords: words(`and as assert async await break class continue def del
+			elif else except finally for from global if import in is lambda
+			nonlocal not or pass raise return try while with yield match case`),
+		types: words(`True False None bool bytes dict float int list object
+			set str tuple type print len range self`),
+	},
+	"fortran": {
+		lineComments: []string{"!"},
+		quotes:       `"'`,
+		foldCase:     true,
+		keywords: words(`allocatable allocate call case contains cycle
+			deallocate dimension do else elseif end enddo endif exit function
+			go goto if implicit in inout intent interface module none only out
+			parameter print procedure program read recursive result return
+			save select stop subroutine then to type use where while write`),
+		types: words(`character complex double integer logical precision real`),
+	},
+	"sh": {
+		lineComments: []string{"#"},
+		wordComments: true,
+		quotes:       `"'`,
+		keywords: words(`case do done elif else esac export fi for function if
+			in local return select then until while`),
+		types: words(`cd echo exit printf read set shift source test unset`),
+	},
+}
+
+// highlight returns the provided source code, with tokens colored using
+// \textcolor commands, and LaTeX command characters escaped for a
+// fancyvrb Verbatim environment with commandchars=\\\{\}.
+func highlight(lang, src string) string {
+	syn, ok := syntaxes[lang]
+	if !ok {
+		return escapeVerbatim(src)
+	}
+
+	var o strings.Builder
+	for _, tok := range syn.tokenize(src) {
+		if tok.kind == tokText {
+			o.WriteString(escapeVerbatim(tok.text))
+			continue
+		}
+		// commands can not span multiple lines: color each line.
+		for i, line := range strings.Split(tok.text, "\n") {
+			if i > 0 {
+				o.WriteString("\n")
+			}
+			if line == "" {
+				continue
+			}
+			o.WriteString(`\textcolor{code` + string(tok.kind) + `}{`)
+			o.WriteString(escapeVerbatim(line))
+			o.WriteString(`}`)
+		}
+	}
+	return o.String()
+}
+
+var verbatimRepl = strings.NewReplacer(
+	`\`, `\char92{}`,
+	`{`, `\char123{}`,
+	`}`, `\char125{}`,
+)
+
+func escapeVerbatim(s string) string {
+	return verbatimRepl.Replace(s)
+}
+
+func (syn *syntax) tokenize(src string) []token {
+	var (
+		toks []token
+		bol  = true // at beginning of line (modulo whitespace)
+		bow  = true // at beginning of a word
+	)
+	emit := func(kind tokenKind, text string) {
+		if n := len(toks); n > 0 && toks[n-1].kind == kind {
+			toks[n-1].text += text
+			return
+		}
+		toks = append(toks, token{kind, text})
+	}
+
+	for len(src) > 0 {
+		r, size := utf8.DecodeRuneInString(src)
+		switch {
+		case r == '\n':
+			emit(tokText, "\n")
+			src = src[size:]
+			bol, bow = true, true
+			continue
+
+		case r == ' ' || r == '\t':
+			emit(tokText, src[:size])
+			src = src[size:]
+			bow = true
+			continue
+
+		case bol && syn.preproc && r == '#':
+			end := lineEnd(src)
+			emit(tokPreproc, src[:end])
+			src = src[end:]
+
+		case syn.isLineComment(src) && (bow || !syn.wordComments):
+			end := lineEnd(src)
+			emit(tokComment, src[:end])
+			src = src[end:]
+
+		case syn.blockComment[0] != "" && strings.HasPrefix(src, syn.blockComment[0]):
+			end := len(src)
+			if i := strings.Index(src[len(syn.blockComment[0]):], syn.blockComment[1]); i >= 0 {
+				end = len(syn.blockComment[0]) + i + len(syn.blockComment[1])
+			}
+			emit(tokComment, src[:end])
+			src = src[end:]
+
+		case syn.tripleQuotes && (strings.HasPrefix(src, `"""`) || strings.HasPrefix(src, `'''`)):
+			end := len(src)
+			if i := strings.Index(src[3:], src[:3]); i >= 0 {
+				end = 3 + i + 3
+			}
+			emit(tokString, src[:end])
+			src = src[end:]
+
+		case strings.ContainsRune(syn.rawQuotes, r):
+			end := len(src)
+			if i := strings.IndexRune(src[size:], r); i >= 0 {
+				end = size + i + size
+			}
+			emit(tokString, src[:end])
+			src = src[end:]
+
+		case strings.ContainsRune(syn.quotes, r):
+			end := quoteEnd(src, r)
+			emit(tokString, src[:end])
+			src = src[end:]
+
+		case unicode.IsDigit(r):
+			end := strings.IndexFunc(src, func(r rune) bool {
+				return !(unicode.IsDigit(r) || unicode.IsLetter(r) || r == '.' || r == '_')
+			})
+			if end < 0 {
+				end = len(src)
+			}
+			emit(tokNumber, src[:end])
+			src = src[end:]
+
+		case unicode.IsLetter(r) || r == '_':
+			end := strings.IndexFunc(src, func(r rune) bool {
+				return !(unicode.IsDigit(r) || unicode.IsLetter(r) || r == '_')
+			})
+			if end < 0 {
+				end = len(src)
+			}
+			word := src[:end]
+			key := word
+			if syn.foldCase {
+				key = strings.ToLower(word)
+			}
+			switch {
+			case syn.keywords[key]:
+				emit(tokKeyword, word)
+			case syn.types[key]:
+				emit(tokType, word)
+			default:
+				emit(tokText, word)
+			}
+			src = src[end:]
+
+		default:
+			emit(tokText, src[:size])
+			src = src[size:]
+		}
+		bol, bow = false, false
+	}
+	return toks
+}
+
+func (syn *syntax) isLineComment(src string) bool {
+	for _, v := range syn.lineComments {
+		if strings.HasPrefix(src, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineEnd returns the index of the end of the current line.
+func lineEnd(src string) int {
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return i
+	}
+	return len(src)
+}
+
+// quoteEnd returns the index of the end of the quoted string starting at
+// the beginning of src. Quoted strings end at the end of the line.
+func quoteEnd(src string, quote rune) int {
+	escaped := false
+	for i, r := range src {
+		switch {
+		case i == 0:
+			continue
+		case r == '\n':
+			return i
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == quote:
+			return i + utf8.RuneLen(r)
+		}
+	}
+	return len(src)
+}
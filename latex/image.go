@@ -5,13 +5,19 @@
 package latex
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
 	"os"
+	"path/filepath"
+	"strings"
 
 	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
 
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
@@ -19,27 +25,136 @@ import (
 	_ "golang.org/x/image/webp"
 )
 
-func inferDims(fname string) (w, h int, err error) {
-	f, err := os.Open(fname)
+// Image is an image file that can be included in a LaTeX document.
+type Image struct {
+	Name   string // name of the image file
+	Width  int    // nominal width of the image, in pixels (0 if unknown)
+	Height int    // nominal height of the image, in pixels (0 if unknown)
+}
+
+// Transcoder converts image files to formats that \includegraphics can load.
+type Transcoder struct {
+	Dir   string // directory holding the converted image files. Default: present-tex directory under the user cache directory.
+	Lossy bool   // whether converted raster images may use lossy (JPEG) compression
+	DPI   int    // resolution of the document, used to rasterize SVG images. Default: 72.
+
+	// Warn, if not nil, is called with the name of images converted
+	// with a loss of content (e.g. unsupported SVG features.)
+	Warn func(name, msg string)
+}
+
+// dir returns the directory holding the converted image files.
+func (t Transcoder) dir() string {
+	if t.Dir != "" {
+		return t.Dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "present-tex")
+}
+
+// Image returns an image file with the provided content of the named
+// file, in a format that \includegraphics can load.
+//
+// PNG, JPEG and non-image files (e.g. PDF) are returned as is.
+// SVG images are rasterized and other raster formats (GIF, BMP,
+// TIFF, WebP, ...) are re-encoded, into the Dir directory.
+func (t Transcoder) Image(fname string, raw []byte) (Image, error) {
+	if strings.EqualFold(filepath.Ext(fname), ".svg") {
+		return t.rasterizeSVG(fname, raw)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		// not a raster image: let the LaTeX engine deal with it.
+		return Image{Name: fname}, nil
+	case err != nil:
+		return Image{}, fmt.Errorf("error decoding image file [%s]: %w", fname, err)
+	}
+
+	img := Image{Name: fname, Width: cfg.Width, Height: cfg.Height}
+	switch format {
+	case "png", "jpeg":
+		return img, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return Image{}, fmt.Errorf("error decoding image file [%s]: %w", fname, err)
+	}
+
+	ext := ".png"
+	if t.Lossy {
+		ext = ".jpg"
+	}
+	img.Name = filepath.Join(t.dir(), cacheName(fname, raw, ext))
+	if _, err := os.Stat(img.Name); err == nil {
+		return img, nil
+	}
+
+	buf := new(bytes.Buffer)
+	switch ext {
+	case ".jpg":
+		// JPEG has no alpha channel: flatten the image on a white background.
+		dst := image.NewRGBA(src.Bounds())
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: 90})
+	default:
+		err = png.Encode(buf, src)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("could not encode image file [%s]: %w", fname, err)
+	}
+
+	err = writeCache(img.Name, buf.Bytes())
 	if err != nil {
-		return 0, 0, fmt.Errorf(
-			"error opening file [%s]: %w",
-			fname,
-			err,
-		)
+		return Image{}, fmt.Errorf("could not write converted image file [%s]: %w", fname, err)
 	}
+
+	return img, nil
+}
+
+// cacheName returns a file name for the converted version of the named
+// file, that changes with the content of the file.
+func cacheName(name string, content []byte, ext string) string {
+	base := filepath.Base(name)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%s-%x%s", base, sum[:6], ext)
+}
+
+// writeCache atomically writes the provided content to the named file.
+func writeCache(fname string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(fname), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 
-	img, _, err := image.Decode(f)
+	_, err = f.Write(content)
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), 0644)
 	if err != nil {
-		return 0, 0, fmt.Errorf(
-			"error decoding image file [%s]: %w",
-			fname,
-			err,
-		)
+		return err
 	}
-	h = img.Bounds().Dy()
-	w = img.Bounds().Dx()
 
-	return w, h, nil
+	return os.Rename(f.Name(), fname)
 }
@@ -9,35 +9,141 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 
 	"github.com/yuin/goldmark/ast"
+	tast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/util"
 )
 
 // Renderer renders a CommonMark document as LaTeX-Beamer.
 type Renderer struct {
-	dpi   int
-	w     writer
-	funcs map[ast.NodeKind]renderFunc
+	dpi      int
+	read     func(name string) ([]byte, error) // reads files referenced by the document
+	code     string                            // code backend
+	engine   string                            // LaTeX engine
+	images   Transcoder                        // converts images to formats LaTeX can load
+	asset    AssetFunc                         // maps referenced files to their names in the document
+	unmapped func(r rune)                      // called with runes without a LaTeX equivalent
+	warn     func(line int, msg string)        // called with warnings about dropped or degraded content
+	w        writer
+	funcs    map[ast.NodeKind]renderFunc
+
+	hasCode     bool // whether a fenced code block was rendered
+	hasTable    bool // whether a table was rendered
+	hasStrike   bool // whether strikethrough text was rendered
+	hasTaskList bool // whether a task list was rendered
 }
 
 type renderFunc func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error)
 
+// Option configures a Renderer.
+type Option func(r *Renderer)
+
+// WithReadFile sets the function used to read the files referenced
+// by the document (e.g. images.)
+func WithReadFile(f func(name string) ([]byte, error)) Option {
+	return func(r *Renderer) {
+		r.read = f
+	}
+}
+
+// WithTranscoder sets the transcoder used to convert images to formats
+// LaTeX can load.
+func WithTranscoder(t Transcoder) Option {
+	return func(r *Renderer) {
+		r.images = t
+	}
+}
+
+// WithCodeBackend sets the backend used to typeset source code
+// (Minted, Listings or Native.)
+func WithCodeBackend(name string) Option {
+	return func(r *Renderer) {
+		r.code = name
+	}
+}
+
+// WithEngine sets the LaTeX engine the document is rendered for
+// (PDFLaTeX, XeLaTeX or LuaLaTeX.)
+func WithEngine(name string) Option {
+	return func(r *Renderer) {
+		r.engine = name
+	}
+}
+
+// AssetFunc is called with the name of a file referenced by a document
+// and the name of the file actually included in the LaTeX document:
+// either name itself or the path to a converted version of that file.
+// It returns the name to use in the LaTeX document.
+type AssetFunc func(name, path string) string
+
+// WithAssets sets the function called for each file referenced by
+// the rendered document (e.g. images.)
+func WithAssets(f AssetFunc) Option {
+	return func(r *Renderer) {
+		r.asset = f
+	}
+}
+
+// WithUnmapped sets the function called with each rune that has no
+// LaTeX equivalent, for engines without native Unicode support.
+func WithUnmapped(f func(r rune)) Option {
+	return func(r *Renderer) {
+		r.unmapped = f
+	}
+}
+
+// WithWarn sets the function called with warnings about content that
+// could not be rendered as LaTeX, and was dropped or degraded
+// (e.g. raw HTML), with the line of that content in the Markdown source.
+func WithWarn(f func(line int, msg string)) Option {
+	return func(r *Renderer) {
+		r.warn = f
+	}
+}
+
+// warnf reports a rendering warning about the content starting at the
+// provided offset of the Markdown source.
+func (r *Renderer) warnf(source []byte, offset int, format string, args ...interface{}) {
+	if r.warn == nil {
+		return
+	}
+	line := 1 + bytes.Count(source[:offset], []byte("\n"))
+	r.warn(line, fmt.Sprintf(format, args...))
+}
+
+// blockOffset returns the offset of the provided block node in the
+// Markdown source.
+func blockOffset(n ast.Node) int {
+	if n.Lines().Len() == 0 {
+		return 0
+	}
+	return n.Lines().At(0).Start
+}
+
 // New returns a new Renderer.
-func New(dpi int) *Renderer {
+func New(dpi int, opts ...Option) *Renderer {
 	r := &Renderer{
-		dpi:   dpi,
-		w:     newWriter(),
-		funcs: make(map[ast.NodeKind]renderFunc),
+		dpi:    dpi,
+		code:   Minted,
+		engine: PDFLaTeX,
+		read:   os.ReadFile,
+		asset:  func(name, path string) string { return path },
+		w:      newWriter(),
+		funcs:  make(map[ast.NodeKind]renderFunc),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
 
 	// table
-	// r.register(tast.KindTable, renderTable)
-	// r.register(tast.KindTableHeader, renderTableHeader)
-	// r.register(tast.KindTableRow, renderTableRow)
-	// r.register(tast.KindTableCell, renderTableCell)
+	r.register(tast.KindTable, r.renderTable)
+	r.register(tast.KindTableHeader, r.renderTableHeader)
+	r.register(tast.KindTableRow, r.renderTableRow)
+	r.register(tast.KindTableCell, r.renderTableCell)
 
 	// blocks
 	r.register(ast.KindDocument, r.renderDocument)
@@ -61,8 +167,16 @@ func New(dpi int) *Renderer {
 	r.register(ast.KindText, r.renderText)
 	r.register(ast.KindString, r.renderString)
 
-	// strikethrough
-	//	r.register(tast.KindStrikethrough, r.renderStrikethrough)
+	// GitHub-flavored Markdown
+	r.register(tast.KindStrikethrough, r.renderStrikethrough)
+	r.register(tast.KindTaskCheckBox, r.renderTaskCheckBox)
+	r.register(tast.KindFootnoteLink, r.renderFootnoteLink)
+	r.register(tast.KindFootnoteList, r.renderFootnoteList)
+	r.register(tast.KindFootnoteBacklink, r.renderFootnoteBacklink)
+
+	// math
+	r.register(KindMath, r.renderMath)
+	r.register(KindMathBlock, r.renderMathBlock)
 
 	return r
 }
@@ -94,6 +208,9 @@ func (r *Renderer) Render(w io.Writer, source []byte, node ast.Node) error {
 	return nil
 }
 
+// HasCode returns whether the rendered documents contained source code.
+func (r *Renderer) HasCode() bool { return r.hasCode }
+
 // AddOptions adds given option to this renderer.
 func (r *Renderer) AddOptions(...renderer.Option) {}
 
@@ -201,25 +318,50 @@ func (r *Renderer) renderCodeBlock(w util.BufWriter, source []byte, n ast.Node,
 
 func (r *Renderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n := node.(*ast.FencedCodeBlock)
-	if entering {
-		_, _ = w.WriteString("\n\\begin{minted}")
-		lang := n.Language(source)
-		if lang == nil {
-			lang = []byte("text")
-		}
-		_, _ = w.WriteString("{")
-		r.w.Write(w, lang)
-		_, _ = w.WriteString("}\n")
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	lang := n.Language(source)
+	switch string(bytes.ToLower(lang)) {
+	case "latex", "tex":
+		// raw LaTeX.
+		_ = w.WriteByte('\n')
 		r.writeLines(w, source, n)
-	} else {
-		_, _ = w.WriteString("\\end{minted}\n")
+		return ast.WalkSkipChildren, nil
 	}
-	return ast.WalkContinue, nil
+	r.hasCode = true
+	if len(lang) > 0 && !KnownLang(r.code, string(lang)) {
+		r.warnf(source, n.Info.Segment.Start, "unknown code language %q for the %s code backend", lang, r.code)
+	}
+
+	code := new(bytes.Buffer)
+	l := n.Lines().Len()
+	for i := 0; i < l; i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	_ = w.WriteByte('\n')
+	err := WriteCode(w, r.code, Code{Lang: string(lang), Source: code.Bytes()})
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
 }
 
 func (r *Renderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n := node.(*ast.HTMLBlock)
+	if tex, ok := rawLatex(htmlBlock(n, source)); ok {
+		if entering {
+			_ = w.WriteByte('\n')
+			r.w.RawWrite(w, tex)
+			_ = w.WriteByte('\n')
+		}
+		return ast.WalkSkipChildren, nil
+	}
 	if entering {
+		r.warnf(source, blockOffset(n), "HTML block typeset verbatim")
 		_, _ = w.WriteString("\n\\begin{verbatim}\n") // FIXME(sbinet)
 		l := n.Lines().Len()
 		for i := 0; i < l; i++ {
@@ -252,7 +394,12 @@ func (r *Renderer) renderList(w util.BufWriter, source []byte, node ast.Node, en
 	if entering {
 		_, _ = w.WriteString("\n\\begin{")
 		_, _ = w.WriteString(tag)
-		_, _ = w.WriteString("}\n")
+		_, _ = w.WriteString("}")
+		if n.Marker == '+' {
+			// '+' bullet lists are revealed item by item.
+			_, _ = w.WriteString("[<+->]")
+		}
+		_, _ = w.WriteString("\n")
 		//	if n.Attributes() != nil {
 		//		RenderAttributes(w, n, ListAttributeFilter)
 		//	}
@@ -271,6 +418,16 @@ func (r *Renderer) renderList(w util.BufWriter, source []byte, node ast.Node, en
 
 func (r *Renderer) renderListItem(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
+		if box := taskCheckBox(n); box != nil {
+			r.hasTaskList = true
+			switch {
+			case box.IsChecked:
+				_, _ = w.WriteString("\\item[$\\boxtimes$] ")
+			default:
+				_, _ = w.WriteString("\\item[$\\square$] ")
+			}
+			return ast.WalkContinue, nil
+		}
 		_, _ = w.WriteString("\\item ")
 		//		fc := n.FirstChild()
 		//		if fc != nil {
@@ -347,7 +504,9 @@ func (r *Renderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node
 		_, _ = w.WriteString("mailto:")
 	}
 	_, _ = w.Write(bytes.Replace(util.EscapeHTML(util.URLEscape(url, false)), []byte("#"), []byte(`\#`), 1))
-	_, _ = w.WriteString("}{")
+	_, _ = w.WriteString("}{\\texttt{")
+	r.w.RawWrite(w, r.escape(n.Label(source)))
+	_ = w.WriteByte('}')
 	return ast.WalkContinue, nil
 }
 
@@ -359,7 +518,7 @@ func (r *Renderer) renderCodeSpan(w util.BufWriter, source []byte, n ast.Node, e
 		_, _ = w.WriteString("\\texttt{")
 		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
 			segment := c.(*ast.Text).Segment
-			value := escapeLaTeX(segment.Value(source))
+			value := r.escape(segment.Value(source))
 			if bytes.HasSuffix(value, []byte("\n")) {
 				r.w.RawWrite(w, value[:len(value)-1])
 				if c != n.LastChild() {
@@ -435,19 +594,28 @@ func (r *Renderer) renderImage(w util.BufWriter, source []byte, node ast.Node, e
 		return ast.WalkContinue, nil
 	}
 	n := node.(*ast.Image)
+	fname := string(n.Destination)
+	raw, err := r.read(fname)
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("error opening file [%s]: %w", fname, err)
+	}
+	img, err := r.images.Image(fname, raw)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	dst := r.asset(fname, img.Name)
 	_, _ = w.WriteString("\\begin{figure}[h]\n")
 	_, _ = w.WriteString("\\begin{center}\n")
 	_, _ = w.WriteString("\\includegraphics[")
 	switch attrs := n.Attributes(); attrs {
 	case nil:
-		width, height, err := inferDims(string(n.Destination))
-		if err != nil {
-			return ast.WalkStop, err
+		if img.Width == 0 || img.Height == 0 {
+			return ast.WalkStop, fmt.Errorf("could not infer dimensions of image file [%s]", fname)
 		}
 		// FIXME(sbinet): shouldn't this be inches 'in' instead of 'cm' ?
 		// FIXME(sbinet): shouldn't we use a floating point value ?
-		_, _ = w.WriteString(fmt.Sprintf("width=%dcm,", int(float64(width)/float64(r.dpi))))
-		_, _ = w.WriteString(fmt.Sprintf("height=%dcm", int(float64(height)/float64(r.dpi))))
+		_, _ = w.WriteString(fmt.Sprintf("width=%dcm,", int(float64(img.Width)/float64(r.dpi))))
+		_, _ = w.WriteString(fmt.Sprintf("height=%dcm", int(float64(img.Height)/float64(r.dpi))))
 	default:
 		nn := 0
 		for _, attr := range attrs {
@@ -467,7 +635,7 @@ func (r *Renderer) renderImage(w util.BufWriter, source []byte, node ast.Node, e
 		}
 	}
 	_, _ = w.WriteString("]{")
-	_, _ = w.Write(util.EscapeHTML(util.URLEscape(n.Destination, true)))
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(dst), true)))
 	//	if n.Attributes() != nil {
 	//		RenderAttributes(w, n, ImageAttributeFilter)
 	//	}
@@ -482,14 +650,54 @@ func (r *Renderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node,
 		return ast.WalkSkipChildren, nil
 	}
 	n := node.(*ast.RawHTML)
+	raw := new(bytes.Buffer)
 	l := n.Segments.Len()
 	for i := 0; i < l; i++ {
 		segment := n.Segments.At(i)
-		_, _ = w.Write(segment.Value(source))
+		raw.Write(segment.Value(source))
+	}
+	if tex, ok := rawLatex(raw.Bytes()); ok {
+		r.w.RawWrite(w, tex)
+		return ast.WalkSkipChildren, nil
 	}
+	r.warnf(source, n.Segments.At(0).Start, "inline HTML %q written as is", raw)
+	_, _ = w.Write(raw.Bytes())
 	return ast.WalkSkipChildren, nil
 }
 
+// htmlBlock returns the content of the provided HTML block.
+func htmlBlock(n *ast.HTMLBlock, source []byte) []byte {
+	raw := new(bytes.Buffer)
+	l := n.Lines().Len()
+	for i := 0; i < l; i++ {
+		line := n.Lines().At(i)
+		raw.Write(line.Value(source))
+	}
+	if n.HasClosure() {
+		raw.Write(n.ClosureLine.Value(source))
+	}
+	return raw.Bytes()
+}
+
+// rawLatex returns the content of a <!--latex ... --> HTML comment,
+// holding raw LaTeX.
+func rawLatex(raw []byte) ([]byte, bool) {
+	const (
+		prefix = "<!--latex"
+		suffix = "-->"
+	)
+	raw = bytes.TrimSpace(raw)
+	if !bytes.HasPrefix(raw, []byte(prefix)) || !bytes.HasSuffix(raw, []byte(suffix)) {
+		return nil, false
+	}
+	raw = raw[len(prefix) : len(raw)-len(suffix)]
+	if len(raw) > 0 && !isSpace(raw[0]) {
+		// e.g. <!--latexmk ... -->
+		return nil, false
+	}
+	return bytes.TrimSpace(raw), true
+}
+
 func (r *Renderer) renderText(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	if !entering {
 		return ast.WalkContinue, nil
@@ -499,7 +707,7 @@ func (r *Renderer) renderText(w util.BufWriter, source []byte, node ast.Node, en
 	if n.IsRaw() {
 		r.w.RawWrite(w, segment.Value(source))
 	} else {
-		r.w.Write(w, escapeLaTeX(segment.Value(source)))
+		r.w.Write(w, r.escape(segment.Value(source)))
 		if n.SoftLineBreak() {
 			_ = w.WriteByte('\n')
 		}
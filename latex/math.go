@@ -0,0 +1,190 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// MathExtension is a goldmark extension parsing LaTeX math:
+// inline math ($...$), display math ($$...$$) and display math blocks
+// (lines between two $$ lines.)
+// Math content is emitted verbatim by the Renderer.
+var MathExtension goldmark.Extender = mathExtension{}
+
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 750)),
+		parser.WithInlineParsers(util.Prioritized(mathParser{}, 500)),
+	)
+}
+
+// KindMath is the ast.NodeKind of Math nodes.
+var KindMath = ast.NewNodeKind("Math")
+
+// Math is an inline ast.Node holding LaTeX math.
+type Math struct {
+	ast.BaseInline
+	Display bool         // whether this is display math ($$...$$)
+	Segment text.Segment // math content, without delimiters
+}
+
+// Dump implements ast.Node.Dump.
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Display": map[bool]string{true: "true", false: "false"}[n.Display],
+		"Value":   string(n.Segment.Value(source)),
+	}, nil)
+}
+
+// Kind implements ast.Node.Kind.
+func (n *Math) Kind() ast.NodeKind { return KindMath }
+
+// KindMathBlock is the ast.NodeKind of MathBlock nodes.
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathBlock is a block ast.Node holding LaTeX display math.
+type MathBlock struct {
+	ast.BaseBlock
+}
+
+// Dump implements ast.Node.Dump.
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// Kind implements ast.Node.Kind.
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+// IsRaw implements ast.Node.IsRaw.
+func (n *MathBlock) IsRaw() bool { return true }
+
+type mathParser struct{}
+
+func (mathParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, seg := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	// as in pandoc, inline math must not start with a space, so that
+	// prices ($5 and $10) are not taken for math.
+	if len(line) <= delim || (delim == 1 && isSpace(line[1])) {
+		return nil
+	}
+
+	end := -1
+Loop:
+	for i := delim; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] != '$':
+		case delim == 2:
+			if i+1 < len(line) && line[i+1] == '$' {
+				end = i
+				break Loop
+			}
+		case !isSpace(line[i-1]) && (i+1 == len(line) || !isDigit(line[i+1])):
+			end = i
+			break Loop
+		default:
+			// a '$' that can not close inline math is not part of it:
+			// the opening '$' is text.
+			break Loop
+		}
+	}
+	if end <= delim {
+		return nil
+	}
+
+	block.Advance(end + delim)
+	return &Math{
+		Display: delim == 2,
+		Segment: text.NewSegment(seg.Start+delim, seg.Start+end),
+	}
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	if !bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	advanceToEOL(reader, line)
+	return &MathBlock{}, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, seg := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		advanceToEOL(reader, line)
+		return parser.Close
+	}
+	node.Lines().Append(seg)
+	advanceToEOL(reader, line)
+	return parser.Continue | parser.NoChildren
+}
+
+// advanceToEOL advances the reader to the end of the current line,
+// leaving the line terminator to the block parser.
+func advanceToEOL(reader text.Reader, line []byte) {
+	n := len(line)
+	if n > 0 && line[n-1] == '\n' {
+		n--
+	}
+	reader.Advance(n)
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func (r *Renderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Math)
+	open, close := "$", "$"
+	if n.Display {
+		open, close = `\[`, `\]`
+	}
+	_, _ = w.WriteString(open)
+	r.w.RawWrite(w, n.Segment.Value(source))
+	_, _ = w.WriteString(close)
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("\\[\n")
+	r.writeLines(w, source, node)
+	_, _ = w.WriteString("\\]\n")
+	return ast.WalkSkipChildren, nil
+}
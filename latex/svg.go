@@ -0,0 +1,89 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// svgOversampling is the factor by which SVG images are oversampled,
+// relative to the document resolution, so they still look crisp when
+// projected.
+const svgOversampling = 4
+
+// rasterizeSVG rasterizes the named SVG image, with the provided content,
+// to a PNG file in the transcoder directory.
+// The returned image has the nominal size of the SVG image.
+func (t Transcoder) rasterizeSVG(fname string, raw []byte) (Image, error) {
+	icon, serr := oksvg.ReadIconStream(bytes.NewReader(raw), oksvg.StrictErrorMode)
+	if serr != nil {
+		// rasterize what can be, without the unsupported elements.
+		var err error
+		icon, err = oksvg.ReadIconStream(bytes.NewReader(raw), oksvg.IgnoreErrorMode)
+		if err != nil {
+			return Image{}, fmt.Errorf("could not parse SVG image %q: %w", fname, err)
+		}
+		if t.Warn != nil {
+			t.Warn(fname, fmt.Sprintf("SVG image %q rasterized without its unsupported elements: %v", fname, serr))
+		}
+	}
+
+	var (
+		w = int(math.Ceil(icon.ViewBox.W))
+		h = int(math.Ceil(icon.ViewBox.H))
+	)
+	if w <= 0 || h <= 0 {
+		return Image{}, fmt.Errorf("invalid SVG image %q dimensions (w=%d, h=%d)", fname, w, h)
+	}
+
+	// the image is displayed at its nominal size in DPI units:
+	// a higher resolution yields a larger bitmap.
+	dpi := t.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	scale := svgOversampling * float64(dpi) / 72
+
+	img := Image{
+		Name:   filepath.Join(t.dir(), cacheName(fname, raw, fmt.Sprintf("-%ddpi.png", dpi))),
+		Width:  w,
+		Height: h,
+	}
+	if _, err := os.Stat(img.Name); err == nil {
+		return img, nil
+	}
+
+	var (
+		sw  = int(math.Ceil(float64(w) * scale))
+		sh  = int(math.Ceil(float64(h) * scale))
+		dst = image.NewRGBA(image.Rect(0, 0, sw, sh))
+	)
+	icon.SetTarget(0, 0, float64(sw), float64(sh))
+	scan := rasterx.NewScannerGV(sw, sh, dst, dst.Bounds())
+	icon.Draw(rasterx.NewDasher(sw, sh, scan), 1)
+
+	buf := new(bytes.Buffer)
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	err := enc.Encode(buf, dst)
+	if err != nil {
+		return Image{}, fmt.Errorf("could not encode SVG image %q to PNG: %w", fname, err)
+	}
+
+	err = writeCache(img.Name, buf.Bytes())
+	if err != nil {
+		return Image{}, fmt.Errorf("could not write SVG image %q to cache: %w", fname, err)
+	}
+
+	return img, nil
+}
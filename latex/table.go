@@ -0,0 +1,77 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"github.com/yuin/goldmark/ast"
+	tast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// HasTable returns whether the rendered documents contained tables.
+// Tables are typeset with the booktabs package.
+func (r *Renderer) HasTable() bool { return r.hasTable }
+
+func (r *Renderer) renderTable(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*tast.Table)
+	if !entering {
+		_, _ = w.WriteString("\\bottomrule\n")
+		_, _ = w.WriteString("\\end{tabular}}\n")
+		_, _ = w.WriteString("\\end{center}\n")
+		return ast.WalkContinue, nil
+	}
+	r.hasTable = true
+
+	_, _ = w.WriteString("\n\\begin{center}\n")
+	// shrink tables wider than the frame.
+	_, _ = w.WriteString("\\resizebox{\\ifdim\\width>\\linewidth\\linewidth\\else\\width\\fi}{!}{%\n")
+	_, _ = w.WriteString("\\begin{tabular}{")
+	for _, align := range n.Alignments {
+		switch align {
+		case tast.AlignRight:
+			_ = w.WriteByte('r')
+		case tast.AlignCenter:
+			_ = w.WriteByte('c')
+		default:
+			_ = w.WriteByte('l')
+		}
+	}
+	_, _ = w.WriteString("}\n")
+	_, _ = w.WriteString("\\toprule\n")
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTableHeader(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString(" \\\\\n")
+		_, _ = w.WriteString("\\midrule\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTableRow(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString(" \\\\\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTableCell(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*tast.TableCell)
+	_, header := n.Parent().(*tast.TableHeader)
+	if !entering {
+		if header {
+			_ = w.WriteByte('}')
+		}
+		return ast.WalkContinue, nil
+	}
+	if n.PreviousSibling() != nil {
+		_, _ = w.WriteString(" & ")
+	}
+	if header {
+		_, _ = w.WriteString("\\textbf{")
+	}
+	return ast.WalkContinue, nil
+}
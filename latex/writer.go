@@ -5,8 +5,6 @@
 package latex
 
 import (
-	"strings"
-
 	"github.com/yuin/goldmark/util"
 )
 
@@ -40,80 +38,19 @@ func (w *writer) Write(wbuf util.BufWriter, src []byte) {
 	w.RawWrite(wbuf, src)
 }
 
-// UTF8 replaces UTF8 code sequences with their LaTeX equivalent.
-func UTF8(s string) string {
-	return latexUTF8.Replace(s)
-}
-
 // escapeLaTeX escapes characters that should be escaped in LaTeX text.
 func escapeLaTeX(src []byte) []byte {
 	return []byte(latexRepl.Replace(string(src)))
 }
 
-var (
-	latexRepl = strings.NewReplacer(
-		"-->", `$\Rightarrow$ `,
-		"<--", `$\Leftarrow$ `,
-		"->", `$\rightarrow$ `,
-		"<-", `$\leftarrow$ `,
-		"⇒", `$\Rightarrow$ `,
-		"—", `---`,
-
-		`\`, `\textbackslash`,
-		"_", `\_`,
-		"&", `\&`,
-		"$", `\$`,
-		"^", `\^{}`,
-		"%", `\%`,
-		"~", `$\sim$`,
-		"#", `\#`,
-		"{", `\{`,
-		"}", `\}`,
-		"é", `\'e`,
-		"è", `\`+"`e",
-		"à", `\`+"`a",
-		"ù", `\`+"`u",
-		"â", `\^a`,
-		"ê", `\^e`,
-		"î", `\^i`,
-		"ô", `\^o`,
-		"û", `\^u`,
-		"ŷ", `\^y`,
-		"ä", `\"a`,
-		"ë", `\"e`,
-		"ï", `\"i`,
-		"ö", `\"o`,
-		"ü", `\"u`,
-		"ÿ", `\"y`,
-		"ç", `\c{c}`,
-		"Ç", `\c{C}`,
-		"æ", `\ae `,
-		"œ", `\oe `,
-	)
-
-	latexUTF8 = strings.NewReplacer(
-		"⇒", `$\Rightarrow$ `,
-		"—", `---`,
+// escape escapes characters that should be escaped in LaTeX text,
+// for the LaTeX engine of the renderer.
+func (r *Renderer) escape(src []byte) []byte {
+	src = escapeLaTeX(src)
+	if Unicode(r.engine) {
+		return src
+	}
+	return []byte(Transliterate(string(src), r.unmapped))
+}
 
-		"é", `\'e`,
-		"è", `\`+"`e",
-		"à", `\`+"`a",
-		"ù", `\`+"`u",
-		"â", `\^a`,
-		"ê", `\^e`,
-		"î", `\^i`,
-		"ô", `\^o`,
-		"û", `\^u`,
-		"ŷ", `\^y`,
-		"ä", `\"a`,
-		"ë", `\"e`,
-		"ï", `\"i`,
-		"ö", `\"o`,
-		"ü", `\"u`,
-		"ÿ", `\"y`,
-		"ç", `\c{c}`,
-		"Ç", `\c{C}`,
-		"æ", `\ae `,
-		"œ", `\oe `,
-	)
-)
+var latexRepl = NewEscaper()
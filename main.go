@@ -6,37 +6,95 @@
 //
 // Usage of present-tex:
 //
-//   $ present-tex [options] [input-file [output.tex]]
+//	$ present-tex [options] [input-file [output.tex]]
+//	$ present-tex build [options] [input-file [output.pdf]]
+//	$ present-tex [build] -o outdir [options] path...
 //
 // Examples:
-//   $ present-tex input.slide > out.tex
-//   $ present-tex input.slide out.tex
-//   $ present-tex < input.slide > out.tex
+//
+//	$ present-tex input.slide > out.tex
+//	$ present-tex input.slide out.tex
+//	$ present-tex < input.slide > out.tex
+//	$ present-tex -root=talks/go < talks/go/input.slide > out.tex
+//	$ present-tex -bundle talk.zip input.slide
+//	$ present-tex build input.slide
+//	$ present-tex build -build-cmd=latexmk input.slide out.pdf
+//	$ present-tex -watch input.slide out.tex
+//	$ present-tex build -watch input.slide
+//	$ present-tex -o out talks/...
+//	$ present-tex build -o out -j 4 talks/...
+//
+// The build command converts the input file and compiles it into a PDF
+// document, running the LaTeX engine until cross-references are resolved.
+//
+// With -o, the present documents found under the input files, directories
+// and "dir/..." patterns are converted (or built) into the output
+// directory, mirroring their paths, in parallel.
+// Directories starting with '_' or '.' are not searched.
+// Documents whose output is newer than the files they reference, and
+// was converted with the same settings, are skipped.
+//
+// In watch mode, the input file is converted (or built) again each time
+// it, or one of the files it references, changes.
+//
+// Settings of a document may be read from present-tex.toml files, in the
+// directory of the document or in its parent directories, the nearest
+// ones taking precedence. Command-line flags take precedence over them.
+//
+//	theme = "Madrid"
+//	color-theme = "beaver"
+//	font-theme = "serif"
+//	aspect-ratio = "16:9"
+//	dpi = 72
+//	templates = "_templates"
+//	engine = "xelatex"
+//	code-backend = "listings"
+//	lang = "fr"
+//	logo = "_figs/logo.png"
+//	preamble = '\usepackage{tikz}'
+//
+//	# settings of a document, relative to the directory of the file.
+//	[files."2021/talk.slide"]
+//	theme = "Berkeley"
+//
+// Paths are resolved against the directory of the present-tex.toml file.
+//
+// Content of the input file that is dropped or degraded by the
+// conversion (e.g. raw HTML) is reported as warnings, in the
+// "file:line: message" form editors can jump to, followed by a summary.
+// In strict mode, warnings make present-tex exit with a non-zero status.
 //
 // Options:
-//   -base="": base path for slide templates
+//
+//	-base="": base path for slide templates
+//	-color-theme="": Beamer color theme (e.g. beaver)
+//	-font-theme="": Beamer font theme (e.g. serif)
+//	-aspect-ratio="": aspect ratio of the slides (e.g. 16:9)
+//	-logo="": image displayed on each slide
+//	-root="": directory against which relative paths are resolved
+//	-lang="": babel language of the document (e.g. french or fr)
+//	-build-cmd="": LaTeX command used by build (default: -engine)
+//	-watch=false: convert again when the input file or its assets change
+//	-strict=false: exit with a non-zero status on warnings
+//	-json-diagnostics=false: report warnings and errors as JSON objects
+//	-o="": output directory of the documents found under the inputs
+//	-j=NumCPU: number of documents converted in parallel, with -o
 package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"html"
-	"html/template"
 	"io"
-	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 
-	"golang.org/x/tools/present"
-)
-
-var (
-	tmpl        *template.Template // beamer template
-	hasCode     = false            // whether the .slide has a .code or .play directive
-	beamerTheme = flag.String("beamer-theme", "default", "Beamer theme to use (e.g: Berkeley, Madrid, ...)")
-	dpi         = flag.Int("dpi", 72, "DPI resolution to use for PDF")
+	"github.com/sbinet/present-tex/beamer"
 )
 
 func main() {
@@ -46,12 +104,22 @@ func main() {
 Usage of %[1]s:
 
 $ %[1]s [options] [input-file [output.tex]]
+$ %[1]s build [options] [input-file [output.pdf]]
+$ %[1]s [build] -o outdir [options] path...
 
 Examples:
 
 $ %[1]s input.slide > out.tex
 $ %[1]s input.slide out.tex
 $ %[1]s < input.slide > out.tex
+$ %[1]s -root=talks/go < talks/go/input.slide > out.tex
+$ %[1]s -bundle talk.zip input.slide
+$ %[1]s build input.slide
+$ %[1]s build -build-cmd=latexmk input.slide out.pdf
+$ %[1]s -watch input.slide out.tex
+$ %[1]s build -watch input.slide
+$ %[1]s -o out talks/...
+$ %[1]s build -o out -j 4 talks/...
 
 Options:
 `,
@@ -63,20 +131,115 @@ Options:
 	log.SetFlags(0)
 	log.SetPrefix("present-tex: ")
 
-	tmpldirFlag := flag.String("base", "", "base path for slide templates")
+	var (
+		tmpldirFlag = flag.String("base", "", "base path for slide templates")
+		beamerTheme = flag.String("beamer-theme", "default", "Beamer theme to use (e.g: Berkeley, Madrid, ...)")
+		colorTheme  = flag.String("color-theme", "", "Beamer color theme to use (e.g: beaver, whale, ...)")
+		fontTheme   = flag.String("font-theme", "", "Beamer font theme to use (e.g: serif, structurebold, ...)")
+		aspectRatio = flag.String("aspect-ratio", "", "aspect ratio of the slides (e.g: 16:9, 4:3, ...)")
+		logo        = flag.String("logo", "", "image displayed on each slide")
+		dpi         = flag.Int("dpi", 72, "DPI resolution to use for PDF")
+		lossy       = flag.Bool("lossy-images", false, "use lossy JPEG compression for converted images")
+		cacheDir    = flag.String("cache", "", "directory holding converted images (default: user cache directory)")
+		notes       = flag.String("notes", "none", "speaker notes mode (none, only, show, second-screen)")
+		video       = flag.String("video", "link", "video rendering mode (link, movie)")
+		codeBackend = flag.String("code-backend", "minted", "backend to typeset code (minted, listings, native)")
+		secFrames   = flag.Bool("section-frames", false, "render sections without content as a frame with a centered title")
+		engine      = flag.String("engine", "pdflatex", "LaTeX engine the document is written for (pdflatex, xelatex, lualatex)")
+		mainFont    = flag.String("main-font", "", "main font, with xelatex or lualatex")
+		monoFont    = flag.String("mono-font", "", "monospace font, with xelatex or lualatex")
+		cjkFont     = flag.String("cjk-font", "", "CJK font, with xelatex or lualatex")
+		lang        = flag.String("lang", "", "babel language of the document, e.g. french or fr (default: 'lang:' document tag, or english)")
+		rootDir     = flag.String("root", "", "directory against which relative paths are resolved (default: directory of the input file)")
+		bundle      = flag.String("bundle", "", "write a self-contained archive (.zip, .tar or .tar.gz) of the LaTeX document and its assets")
+		watchFlag   = flag.Bool("watch", false, "convert (or build) the input file again each time it or the files it references change")
+		buildCmd    = flag.String("build-cmd", "", "LaTeX command used by build: pdflatex, xelatex, lualatex, latexmk or compatible program (default: -engine)")
+		strict      = flag.Bool("strict", false, "exit with a non-zero status when content was dropped or degraded by the conversion")
+		jsonDiags   = flag.Bool("json-diagnostics", false, "report warnings and errors on stderr as JSON objects, one per line")
+		outDir      = flag.String("o", "", "convert (or build) the documents found under the input files and directories into this directory, mirroring their paths")
+		njobs       = flag.Int("j", runtime.NumCPU(), "number of documents converted in parallel, with -o")
+	)
 
-	flag.Parse()
+	args := os.Args[1:]
+	build := len(args) > 0 && args[0] == "build"
+	if build {
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
 
-	var tmpldir = func() fs.FS {
-		o, err := fs.Sub(tmplFS, "templates")
+	diags := &diagnostics{w: os.Stderr, json: *jsonDiags, strict: *strict}
+	if diags.json {
+		// only report diagnostics, so stderr can be parsed.
+		log.SetOutput(io.Discard)
+	}
+
+	cnv := beamer.Converter{
+		Theme:       *beamerTheme,
+		ColorTheme:  *colorTheme,
+		FontTheme:   *fontTheme,
+		AspectRatio: *aspectRatio,
+		Logo:        *logo,
+		DPI:         *dpi,
+		Notes:       *notes,
+		Video:       *video,
+
+		Engine:   *engine,
+		MainFont: *mainFont,
+		MonoFont: *monoFont,
+		CJKFont:  *cjkFont,
+		Lang:     *lang,
+
+		BuildCmd: *buildCmd,
+
+		Base:          *rootDir,
+		CacheDir:      *cacheDir,
+		LossyImages:   *lossy,
+		CodeBackend:   *codeBackend,
+		SectionFrames: *secFrames,
+
+		Warn: diags.warn,
+	}
+
+	if *tmpldirFlag != "" {
+		cnv.Templates = os.DirFS(*tmpldirFlag)
+	}
+
+	if *outDir != "" {
+		if *bundle != "" || *watchFlag {
+			diags.usage(errors.New("-o can not be used with -bundle or -watch"))
+		}
+		err := runBatch(cnv, build, *outDir, flag.Args(), *njobs, diags)
 		if err != nil {
-			log.Fatalf("could not locate embedded 'templates' directory: %+v", err)
+			diags.fatalf("%+v", err)
 		}
-		return o
-	}()
+		diags.check()
+		return
+	}
 
-	if *tmpldirFlag != "" {
-		tmpldir = os.DirFS(*tmpldirFlag)
+	if *bundle != "" {
+		err := runBundle(cnv, *bundle)
+		if err != nil {
+			diags.fatalf("could not create bundle: %+v", err)
+		}
+		diags.check()
+		return
+	}
+
+	if *watchFlag {
+		err := runWatch(cnv, build, diags)
+		if err != nil {
+			diags.fatalf("%+v", err)
+		}
+		return
+	}
+
+	if build {
+		err := runBuild(cnv)
+		if err != nil {
+			diags.fatalf("%+v", err)
+		}
+		diags.check()
+		return
 	}
 
 	var (
@@ -94,7 +257,7 @@ Options:
 		input = flag.Arg(0)
 		f, err := os.Open(input)
 		if err != nil {
-			log.Fatal(err)
+			diags.fatalf("%+v", err)
 		}
 		defer f.Close()
 		log.Printf("input:  [%s]...\n", input)
@@ -107,22 +270,23 @@ Options:
 		input = flag.Arg(0)
 		f, err := os.Open(input)
 		if err != nil {
-			log.Fatal(err)
+			diags.fatalf("%+v", err)
 		}
 		defer f.Close()
 		log.Printf("input:  [%s]...\n", input)
 
 		output = flag.Arg(1)
 		log.Printf("output: [%s]...\n", output)
+		cnv.OutDir = filepath.Dir(output)
 
 		tex, err := os.Create(output)
 		if err != nil {
-			log.Fatalf("could not create output file [%s]: %v\n", output, err)
+			diags.fatalf("could not create output file: %+v", err)
 		}
 		defer func() {
 			err = tex.Close()
 			if err != nil {
-				log.Fatalf("could not close output file [%s]: %v\n", output, err)
+				diags.fatalf("could not close output file: %+v", err)
 			}
 		}()
 
@@ -134,245 +298,119 @@ Options:
 		os.Exit(2)
 	}
 
-	err := xmain(w, r, input, tmpldir)
+	cnv, _, _, err := configure(cnv, input)
 	if err != nil {
-		log.Fatalf("could not run present-tex: %+v", err)
-	}
-}
-
-func xmain(w io.Writer, r io.Reader, input string, tmpldir fs.FS) error {
-	ctx := present.Context{
-		ReadFile: os.ReadFile,
-		Render:   renderAsLaTeX,
+		diags.fatalf("%+v", err)
 	}
 
-	doc, err := ctx.Parse(r, input, 0)
+	err = cnv.Convert(context.Background(), w, r, input)
 	if err != nil {
-		return fmt.Errorf("could not parse input document: %w", err)
+		diags.fatalf("could not run present-tex: %+v", err)
 	}
+	diags.check()
+}
 
-	err = parseImages(doc)
+func runBundle(cnv beamer.Converter, fname string) error {
+	format, err := beamer.BundleFormat(fname)
 	if err != nil {
-		return fmt.Errorf("could not parse images: %w", err)
+		return err
 	}
 
-	err = parseCode(doc)
-	if err != nil {
-		return fmt.Errorf("could not parse code fragments: %w", err)
+	var (
+		r     io.Reader
+		input = "stdin"
+	)
+	switch flag.NArg() {
+	case 0:
+		r = os.Stdin
+	case 1:
+		input = flag.Arg(0)
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.Printf("input:  [%s]...\n", input)
+		r = f
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
+	log.Printf("bundle: [%s]...\n", fname)
 
-	tmpl, err = initTemplates(tmpldir)
+	cnv, _, _, err = configure(cnv, input)
 	if err != nil {
-		return fmt.Errorf("could not parse templates: %w", err)
+		return err
 	}
 
-	buf := new(bytes.Buffer)
-	err = doc.Render(buf, tmpl)
+	o, err := os.Create(fname)
 	if err != nil {
-		return fmt.Errorf("could not render document: %w", err)
+		return fmt.Errorf("could not create bundle file [%s]: %w", fname, err)
 	}
+	defer o.Close()
 
-	out := []byte(html.UnescapeString(buf.String()))
-
-	_, err = w.Write(out)
+	err = cnv.Bundle(context.Background(), o, r, input, format)
 	if err != nil {
-		return fmt.Errorf("could not fill output: %w", err)
+		return err
 	}
 
-	return nil
-}
-
-func initTemplates(root fs.FS) (*template.Template, error) {
-	tmpl := template.New("").Funcs(funcs).Delims("<<", ">>")
-	_, err := tmpl.ParseFS(root, "beamer.tmpl")
+	err = o.Close()
 	if err != nil {
-		return nil, err
-	}
-
-	return tmpl, err
-}
-
-// renderElem implements the elem template function, used to render
-// sub-templates.
-func renderElem(t *template.Template, e present.Elem) (template.HTML, error) {
-	var data interface{} = e
-	if s, ok := e.(present.Section); ok {
-		data = struct {
-			present.Section
-			Template *template.Template
-		}{s, t}
+		return fmt.Errorf("could not close bundle file [%s]: %w", fname, err)
 	}
-	return execTemplate(t, e.TemplateName(), data)
+	return nil
 }
 
-var (
-	funcs = template.FuncMap{}
-)
-
-func init() {
-	funcs["elem"] = renderElem
-	funcs["stringFromBytes"] = func(raw []byte) string { return string(raw) }
-	funcs["join"] = func(lines []string) string { return strings.Join(lines, "\n") }
-	funcs["nodot"] = func(s string) string {
-		if strings.HasPrefix(s, ".") {
-			return s[1:]
-		}
-		return s
-	}
-	tex1 := strings.NewReplacer(
-		"&", `\&`,
-		"$", `\$`,
-		"^", `\^{}`,
-		"%", `\%`,
-		"~", `$\sim$`,
-		"#", `\#`,
-		"{", `\{`,
-		"}", `\}`,
-		`\`, `\textbackslash`,
-		"é", `\'e`,
-		"è", `\`+"`e",
-		"à", `\`+"`a",
-		"ù", `\`+"`u",
-		"â", `\^a`,
-		"ê", `\^e`,
-		"î", `\^i`,
-		"ô", `\^o`,
-		"û", `\^u`,
-		"ä", `\"a`,
-		"ë", `\"e`,
-		"ï", `\"i`,
-		"ü", `\"u`,
-		"ÿ", `\"y`,
-		"ç", `\c{c}`,
-		"Ç", `\c{C}`,
-		"œ", `\oe `,
-		">", `$>$`,
-		"<", `$<$`,
-		">=", `$\geq$`,
-		"<=", `$\leq$`,
-		"->", `$\rightarrow$`,
-		"=>", `$\Rightarrow$`,
-	)
-	tex2 := strings.NewReplacer(
-		"_", `\_`,
+func runBuild(cnv beamer.Converter) error {
+	var (
+		r      io.Reader
+		input  = "stdin"
+		output = "stdout"
 	)
-
-	style := func(s string) string {
-		s = tex1.Replace(s)
-		s = string(renderStyle(s))
-		s = tex2.Replace(s)
-		return s
-	}
-	funcs["style"] = style
-
-	funcs["beamerTheme"] = func() string {
-		return *beamerTheme
-	}
-
-	funcs["hasCode"] = func() bool {
-		return hasCode
-	}
-
-	funcs["pdfAuthor"] = func(authors []present.Author) string {
-		out := make([]string, 0, len(authors))
-		for _, a := range authors {
-			name, _, _ := parseAuthor(a)
-			if name == "" {
-				continue
-			}
-			out = append(out, fmt.Sprintf("pdfauthor={%s},%%\n", style(name)))
-		}
-		return strings.Join(out, "  ")
-	}
-
-	funcs["texAuthor"] = func(authors []present.Author) string {
-		const hdr = "\\parbox{0.26\\textwidth}{\n\t\\texorpdfstring\n\t  {\n\t\t\\centering\n"
-		out := make([]string, 0, len(authors))
-		shorts := make([]string, 0, len(authors))
-		for _, a := range authors {
-			elems := renderAuthor(a)
-			if len(elems) == 0 {
-				continue
-			}
-			name := elems[0]
-			if name == "" {
-				continue
-			}
-			if len(shorts) > 0 {
-				shorts = append(shorts, "\\&")
-			}
-			shorts = append(shorts, name)
-			if len(out) > 0 {
-				out = append(out, "\\and %\n")
-			}
-			out = append(out, hdr)
-			for _, elem := range elems {
-				out = append(out, "\t\t"+elem+` \\`+"\n")
-			}
-			out = append(out, "\t  }\n\t{"+elems[0]+"}\n}\n")
-		}
-		if len(out) > 0 {
-			out = append([]string{"\\author[" + strings.Join(shorts, " ") + "]{\n"}, out...)
-			out = append(out, "}\n")
+	switch flag.NArg() {
+	case 0:
+		r = os.Stdin
+	case 1, 2:
+		input = flag.Arg(0)
+		f, err := os.Open(input)
+		if err != nil {
+			return err
 		}
-		return strings.Join(out, " ")
-	}
-}
+		defer f.Close()
+		log.Printf("input:  [%s]...\n", input)
+		r = f
 
-func renderAuthor(author present.Author) []string {
-	var elems []string
-	if len(author.Elem) == 0 {
-		return elems
-	}
-	for _, e := range author.Elem {
-		str, err := renderElem(tmpl, e)
-		if err != nil {
-			log.Fatal(err)
+		output = flag.Arg(1)
+		if output == "" {
+			output = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + ".pdf"
 		}
-		elem := html.UnescapeString(string(str))
-		elem = strings.Trim(elem, "\n")
-		elems = append(elems, elem)
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
-	return elems
-}
 
-func parseAuthor(author present.Author) (name string, inst string, mail present.Link) {
-	elems := author.TextElem()
-	if len(elems) == 0 {
-		return
-	}
-	getLines := func(i int) []string {
-		lines := elems[i].(present.Text).Lines
-		return lines
+	cnv, _, _, err := configure(cnv, input)
+	if err != nil {
+		return err
 	}
 
-	name = strings.TrimSpace(getLines(0)[0])
-	if name == "" {
-		return
+	// the PDF document is only written out once the build succeeded,
+	// so a failed build does not clobber a previous document.
+	pdf := new(bytes.Buffer)
+	err = cnv.Build(context.Background(), pdf, r, input)
+	if err != nil {
+		return err
 	}
 
-	if len(elems) > 1 {
-		inst = strings.TrimSpace(getLines(1)[0])
+	if output == "stdout" {
+		_, err = os.Stdout.Write(pdf.Bytes())
+		return err
 	}
-	for _, elem := range author.Elem {
-		link, ok := elem.(present.Link)
-		if !ok {
-			continue
-		}
-		if strings.Contains(link.Label, "@") {
-			mail = link
-		}
-	}
-	return
-}
 
-// execTemplate is a helper to execute a template and return the output as a
-// template.HTML value.
-func execTemplate(t *template.Template, name string, data interface{}) (template.HTML, error) {
-	b := new(bytes.Buffer)
-	err := t.ExecuteTemplate(b, name, data)
+	log.Printf("output: [%s]...\n", output)
+	err = os.WriteFile(output, pdf.Bytes(), 0644)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("could not write PDF document [%s]: %w", output, err)
 	}
-	return template.HTML(b.String()), nil
+	return nil
 }
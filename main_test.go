@@ -6,60 +6,208 @@ package main
 
 import (
 	"bytes"
-	"io/fs"
+	"errors"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func TestConvert(t *testing.T) {
-	var tmpldir = func() fs.FS {
-		o, err := fs.Sub(tmplFS, "templates")
-		if err != nil {
-			t.Fatalf("could not locate embedded 'templates' directory: %+v", err)
-		}
-		return o
-	}()
+func TestMain(m *testing.M) {
+	// the test binary runs the command when re-executed by presentTeX.
+	if os.Getenv("PRESENT_TEX_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// presentTeX runs the command with the provided arguments, from dir, and
+// returns its standard output, standard error and exit code.
+func presentTeX(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PRESENT_TEX_MAIN=1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var eerr *exec.ExitError
+	switch {
+	case err == nil:
+		return stdout.String(), stderr.String(), 0
+	case errors.As(err, &eerr):
+		return stdout.String(), stderr.String(), eerr.ExitCode()
+	default:
+		t.Fatalf("could not run present-tex %q: %+v", args, err)
+		return "", "", 0
+	}
+}
+
+const mainTalk = `# Title
+Subtitle
+
+Author
+
+## Slide
+
+Some text.
+`
+
+const mainTalkHTML = `# Title
+Subtitle
+
+Author
+
+## Slide
 
-	err := os.Chdir("testdata")
+Some <b>raw</b> text.
+`
+
+func TestMainFlags(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "talk.slide"), []byte(mainTalk), 0644)
 	if err != nil {
-		t.Fatalf("could not chdir to testdata: %+v", err)
+		t.Fatalf("could not write input file: %+v", err)
 	}
 
 	for _, tc := range []struct {
-		input string
-		want  string
+		name string
+		args []string
+		want []string
 	}{
 		{
-			input: "talk.slide",
-			want:  "talk_golden.tex",
+			name: "defaults",
+			args: nil,
+			want: []string{
+				`\documentclass[9pt]{beamer}`,
+				`\usetheme{default}`,
+				`\usepackage[utf8]{inputenc}`,
+				`\usepackage[english]{babel}`,
+			},
+		},
+		{
+			name: "theme",
+			args: []string{"-beamer-theme=Madrid"},
+			want: []string{`\usetheme{Madrid}`},
+		},
+		{
+			name: "engine",
+			args: []string{"-engine=xelatex", "-main-font=Libertinus Serif"},
+			want: []string{
+				`\usepackage{fontspec}`,
+				`\setmainfont{Libertinus Serif}`,
+			},
+		},
+		{
+			name: "lang",
+			args: []string{"-lang=fr"},
+			want: []string{`\usepackage[french]{babel}`},
 		},
 		{
-			input: "talk-md.slide",
-			want:  "talk-md_golden.tex",
+			name: "themes",
+			args: []string{"-color-theme=beaver", "-font-theme=serif"},
+			want: []string{`\usecolortheme{beaver}`, `\usefonttheme{serif}`},
+		},
+		{
+			name: "aspect-ratio",
+			args: []string{"-aspect-ratio=16:9"},
+			want: []string{`\documentclass[9pt,aspectratio=169]{beamer}`},
 		},
 	} {
-		t.Run("", func(t *testing.T) {
-			r, err := os.ReadFile(tc.input)
-			if err != nil {
-				t.Fatalf("could not read input file %q: %+v", tc.input, err)
+		t.Run(tc.name, func(t *testing.T) {
+			args := append(tc.args, "talk.slide", "talk.tex")
+			_, stderr, code := presentTeX(t, dir, args...)
+			if code != 0 {
+				t.Fatalf("invalid exit code: got=%d, want=0\n%s", code, stderr)
 			}
-
-			want, err := os.ReadFile(tc.want)
+			got, err := os.ReadFile(filepath.Join(dir, "talk.tex"))
 			if err != nil {
-				t.Fatalf("could not read golden file %q: %+v", tc.want, err)
+				t.Fatalf("could not read output file: %+v", err)
 			}
-
-			w := new(bytes.Buffer)
-			err = xmain(w, bytes.NewReader(r), tc.input, tmpldir)
-			if err != nil {
-				t.Fatalf("could not process document: %+v", err)
+			for _, want := range tc.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output document does not contain %q:\n%s", want, got)
+				}
 			}
+		})
+	}
+}
+
+func TestMainExitCode(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"talk.slide": mainTalk,
+		"html.slide": mainTalkHTML,
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatalf("could not write input file: %+v", err)
+		}
+	}
 
-			if got := w.Bytes(); !bytes.Equal(got, want) {
-				_ = os.WriteFile(tc.input+".tex", got, 0644)
-				out, _ := exec.Command("diff", "-urN", tc.input+".tex", tc.want).CombinedOutput()
-				t.Fatalf("output documents differ: %q:\n%s", tc.input, out)
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string // expected content of the standard output
+		stderr string // expected content of the standard error
+	}{
+		{
+			name:   "stdout",
+			args:   []string{"talk.slide"},
+			code:   0,
+			stdout: `\begin{document}`,
+		},
+		{
+			name:   "missing-input",
+			args:   []string{"missing.slide"},
+			code:   1,
+			stderr: "missing.slide",
+		},
+		{
+			name:   "usage",
+			args:   []string{"talk.slide", "talk.tex", "extra.tex"},
+			code:   2,
+			stderr: "Usage of",
+		},
+		{
+			name:   "warnings",
+			args:   []string{"html.slide"},
+			code:   0,
+			stderr: `html.slide:8: slide "Slide": inline HTML "<b>" written as is`,
+		},
+		{
+			name:   "strict",
+			args:   []string{"-strict", "html.slide"},
+			code:   1,
+			stderr: "2 warning(s) in strict mode",
+		},
+		{
+			name:   "batch-watch",
+			args:   []string{"-o", "out", "-watch", "talk.slide"},
+			code:   2,
+			stderr: "-o can not be used with -bundle or -watch",
+		},
+		{
+			name:   "invalid-setting",
+			args:   []string{"-aspect-ratio=7:3", "talk.slide"},
+			code:   1,
+			stderr: "aspect ratio",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, code := presentTeX(t, dir, tc.args...)
+			if code != tc.code {
+				t.Fatalf("invalid exit code: got=%d, want=%d\n%s", code, tc.code, stderr)
+			}
+			if !strings.Contains(stdout, tc.stdout) {
+				t.Errorf("standard output does not contain %q:\n%s", tc.stdout, stdout)
+			}
+			if !strings.Contains(stderr, tc.stderr) {
+				t.Errorf("standard error does not contain %q:\n%s", tc.stderr, stderr)
 			}
 		})
 	}
@@ -1,10 +0,0 @@
-// Copyright 2021 The present-tex Authors.  All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
-
-package main
-
-import "embed"
-
-//go:embed templates
-var tmplFS embed.FS
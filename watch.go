@@ -0,0 +1,218 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// runWatch converts (or builds) the input document, and converts it
+// again each time the document or one of the files it references change,
+// until interrupted.
+func runWatch(cnv beamer.Converter, build bool, diags *diagnostics) error {
+	var input, output string
+	switch flag.NArg() {
+	case 1, 2:
+		input = flag.Arg(0)
+		output = flag.Arg(1)
+	default:
+		return fmt.Errorf("watch mode needs an input file")
+	}
+	if output == "" {
+		ext := ".tex"
+		if build {
+			ext = ".pdf"
+		}
+		output = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + ext
+	}
+	if !build {
+		cnv.OutDir = filepath.Dir(output)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := &watcher{
+		interval: watchInterval,
+		settle:   watchSettle,
+		run:      watchCommand(cnv, input, output, build, diags),
+		diags:    diags,
+	}
+	log.Printf("watching [%s]...", input)
+	err := w.watch(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// watchCommand returns the command run by the watcher of the input
+// document, converting (or building) it into the output file, and
+// returning the files it depends on: the document, its configuration
+// files, its slide template and the files it references.
+func watchCommand(cnv beamer.Converter, input, output string, build bool, diags *diagnostics) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		files := []string{input}
+		diags.reset()
+		cnv, _, names, err := configure(cnv, input)
+		files = append(files, names...)
+		if err != nil {
+			return files, err
+		}
+		cnv.OnFile = func(name string) { files = append(files, name) }
+		err = convertFile(ctx, cnv, input, output, build)
+		if err == nil {
+			err = diags.summary()
+		}
+		return files, err
+	}
+}
+
+// convertFile converts (or builds) the named input document into the
+// named output file.
+// The output file is left untouched when the conversion fails.
+func convertFile(ctx context.Context, cnv beamer.Converter, input, output string, build bool) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	o := new(bytes.Buffer)
+	switch {
+	case build:
+		err = cnv.Build(ctx, o, f, input)
+	default:
+		err = cnv.Convert(ctx, o, f, input)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(output, o.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write output file [%s]: %w", output, err)
+	}
+	return nil
+}
+
+const (
+	watchInterval = 250 * time.Millisecond // polling interval of watched files
+	watchSettle   = 300 * time.Millisecond // delay without modifications before converting again
+)
+
+// watcher runs a command each time one of the files it depends on changes.
+// Files are polled for modifications.
+type watcher struct {
+	interval time.Duration // polling interval
+	settle   time.Duration // delay without modifications before running the command again
+
+	// run runs the command and returns the files it depends on.
+	run func(ctx context.Context) ([]string, error)
+
+	diags *diagnostics // reports the errors of the command
+
+	stamps map[string]stamp // state of the watched files
+}
+
+// stamp is the state of a watched file.
+type stamp struct {
+	mod  int64 // modification time, in nanoseconds
+	size int64
+	ok   bool // whether the file exists
+}
+
+func newStamp(name string) stamp {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{mod: fi.ModTime().UnixNano(), size: fi.Size(), ok: true}
+}
+
+// watch runs the command, then runs it again after each modification of
+// the files it depends on, until ctx is done.
+// Errors of the command are reported, without stopping watch.
+func (w *watcher) watch(ctx context.Context) error {
+	for {
+		start := time.Now()
+		files, err := w.run(ctx)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch err {
+		case nil:
+			log.Printf("ok (%v)", time.Since(start).Round(time.Millisecond))
+		default:
+			w.diags.error("error: %v", err)
+		}
+
+		stamps := make(map[string]stamp, len(files))
+		if err != nil {
+			// keep watching the files of the previous runs, which
+			// may not have been reached by this failed one.
+			for name, st := range w.stamps {
+				stamps[name] = st
+			}
+		}
+		for _, name := range files {
+			stamps[name] = newStamp(name)
+		}
+		w.stamps = stamps
+
+		err = w.wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for modifications of the watched files, until no other
+// modification happened for the settle delay.
+func (w *watcher) wait(ctx context.Context) error {
+	tick := time.NewTicker(w.interval)
+	defer tick.Stop()
+
+	var last time.Time // time of the last modification
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			switch {
+			case w.changed():
+				last = now
+			case !last.IsZero() && now.Sub(last) >= w.settle:
+				return nil
+			}
+		}
+	}
+}
+
+// changed returns whether any of the watched files was modified since
+// the last call, and records their current state.
+func (w *watcher) changed() bool {
+	changed := false
+	for name, old := range w.stamps {
+		cur := newStamp(name)
+		if cur != old {
+			w.stamps[name] = cur
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The present-tex Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbinet/present-tex/beamer"
+)
+
+// testWatcher runs a watcher with the provided command in the background,
+// and returns a channel receiving the files returned by each run of the
+// command.
+func testWatcher(t *testing.T, settle time.Duration, run func(ctx context.Context) ([]string, error)) <-chan []string {
+	t.Helper()
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		runs        = make(chan []string, 16)
+		done        = make(chan error)
+	)
+	w := &watcher{
+		interval: 5 * time.Millisecond,
+		settle:   settle,
+		run: func(ctx context.Context) ([]string, error) {
+			files, err := run(ctx)
+			runs <- files
+			return files, err
+		},
+		diags: &diagnostics{w: io.Discard},
+	}
+	go func() { done <- w.watch(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		err := <-done
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("invalid watch error: %+v", err)
+		}
+	})
+	return runs
+}
+
+// waitRun waits for the next run of the watched command.
+func waitRun(t *testing.T, runs <-chan []string) []string {
+	t.Helper()
+	select {
+	case files := <-runs:
+		return files
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the command to run")
+		return nil
+	}
+}
+
+// noRun checks the watched command does not run again for the provided
+// duration.
+func noRun(t *testing.T, runs <-chan []string, d time.Duration) {
+	t.Helper()
+	select {
+	case <-runs:
+		t.Fatalf("command run again")
+	case <-time.After(d):
+	}
+}
+
+// touch appends to the named file, so its size and modification time change.
+func touch(t *testing.T, name string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("could not open file: %+v", err)
+	}
+	defer f.Close()
+	_, err = f.WriteString("\n")
+	if err != nil {
+		t.Fatalf("could not write file: %+v", err)
+	}
+}
+
+func TestWatchEdit(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "talk.slide")
+	touch(t, fname)
+
+	runs := testWatcher(t, 20*time.Millisecond, func(ctx context.Context) ([]string, error) {
+		return []string{fname}, nil
+	})
+	waitRun(t, runs)
+
+	touch(t, fname)
+	waitRun(t, runs)
+	noRun(t, runs, 100*time.Millisecond)
+}
+
+func TestWatchBurst(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "talk.slide")
+	touch(t, fname)
+
+	runs := testWatcher(t, 200*time.Millisecond, func(ctx context.Context) ([]string, error) {
+		return []string{fname}, nil
+	})
+	waitRun(t, runs)
+
+	for i := 0; i < 5; i++ {
+		touch(t, fname)
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitRun(t, runs)
+	noRun(t, runs, 300*time.Millisecond)
+}
+
+func TestWatchAssets(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		input  = filepath.Join(dir, "talk.slide")
+		output = filepath.Join(dir, "talk.tex")
+		asset  = filepath.Join(dir, "hello.go")
+	)
+	err := os.WriteFile(input, []byte("Title\n\n* Code\n\n.code hello.go\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write input file: %+v", err)
+	}
+	err = os.WriteFile(asset, []byte("package main\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write asset file: %+v", err)
+	}
+
+	cnv := beamer.Converter{Base: dir, OutDir: dir, CacheDir: t.TempDir()}
+	diags := &diagnostics{w: io.Discard}
+	runs := testWatcher(t, 20*time.Millisecond, watchCommand(cnv, input, output, false, diags))
+
+	files := waitRun(t, runs)
+	if !contains(files, asset) {
+		t.Fatalf("asset %q not watched: %q", asset, files)
+	}
+
+	err = os.WriteFile(asset, []byte("package main\n\nfunc main() {}\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write asset file: %+v", err)
+	}
+	waitRun(t, runs)
+	noRun(t, runs, 100*time.Millisecond)
+
+	tex, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("could not read output file: %+v", err)
+	}
+	if !strings.Contains(string(tex), "func main() {}") {
+		t.Fatalf("output file not converted again:\n%s", tex)
+	}
+}
+
+func contains(vs []string, v string) bool {
+	for _, s := range vs {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}